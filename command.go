@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultLocale = "zh_CN.UTF-8"
+
+// splitShellWords 按 shell 规则切分命令行，支持单引号、双引号和反斜杠转义
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune // 当前所在的引号，0 表示不在引号中
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			// 双引号内只有 $ ` " \ 和换行可以被转义
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("命令行以反斜杠结尾: %q", s)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("命令行引号不匹配: %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// commandLine 根据配置确定要启动的程序和参数
+// 优先级：start_cmd > shell + args > $SHELL > bash
+func commandLine(cfg *Config) ([]string, error) {
+	if strings.TrimSpace(cfg.StartCmd) != "" {
+		words, err := splitShellWords(cfg.StartCmd)
+		if err != nil {
+			return nil, fmt.Errorf("解析 start_cmd 失败: %w", err)
+		}
+		if len(words) > 0 {
+			return words, nil
+		}
+	}
+	if cfg.Shell != "" {
+		return append([]string{cfg.Shell}, cfg.Args...), nil
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return append([]string{shell}, cfg.Args...), nil
+	}
+	if len(cfg.Args) > 0 {
+		return append([]string{"bash"}, cfg.Args...), nil
+	}
+	return []string{"bash", "--norc", "--noprofile", "-i"}, nil
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// buildCommand 根据配置构建子进程命令，包括工作目录和环境变量
+func buildCommand(cfg *Config, screenWidth, screenHeight int) (*exec.Cmd, error) {
+	args, err := commandLine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+
+	if cfg.Cwd != "" {
+		cmd.Dir = expandHome(cfg.Cwd)
+	}
+
+	locale := cfg.Locale
+	if locale == "" {
+		locale = defaultLocale
+	}
+	cmd.Env = append(os.Environ(),
+		"TERM=xterm-256color",
+		"LANG="+locale,
+		"LC_ALL="+locale,
+		fmt.Sprintf("COLUMNS=%d", screenWidth),
+		fmt.Sprintf("LINES=%d", screenHeight),
+	)
+	// 配置中的环境变量最后追加，同名变量以配置为准
+	keys := make([]string, 0, len(cfg.Env))
+	for key := range cfg.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+cfg.Env[key])
+	}
+	return cmd, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	blinkInterval = 500 * time.Millisecond // 光标和闪烁文字的闪烁间隔
+	defaultMaxFPS = 60                     // 未配置 max_fps 时的帧率上限
+)
+
+// notify 通知界面有新的输出，通道已有未处理的通知时直接丢弃
+func (t *Terminal) notify() {
+	select {
+	case t.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// forwardWakeups 将终端的输出通知转发为 SDL 用户事件，唤醒 WaitEventTimeout，子进程退出后结束
+func (a *App) forwardWakeups(t *Terminal) {
+	event := &sdl.UserEvent{Type: a.wakeupEvent}
+	for {
+		select {
+		case <-t.wakeup:
+			sdl.PushEvent(event)
+		case <-t.done:
+			sdl.PushEvent(event)
+			return
+		}
+	}
+}
+
+// frameInterval 两帧之间的最小间隔
+func (a *App) frameInterval() time.Duration {
+	fps := a.Cfg.MaxFPS
+	if fps <= 0 {
+		fps = defaultMaxFPS
+	}
+	return time.Second / time.Duration(fps)
+}
+
+// needsRender 是否有需要绘制的内容：界面状态变化或终端有脏行
+func (a *App) needsRender() bool {
+	return a.redraw || a.terminal.HasDirty()
+}
+
+// waitTimeout 计算等待事件的最长时间：默认等到下一次闪烁，有待绘制的内容时等到帧率限制允许的下一帧，
+// 按住虚拟键盘的按键时等到下一次重复
+func (a *App) waitTimeout() time.Duration {
+	timeout := blinkInterval - time.Since(a.lastBlink)
+	if a.needsRender() {
+		timeout = min(timeout, a.frameInterval()-time.Since(a.lastFrame))
+	}
+	if a.touch.keyDown {
+		timeout = min(timeout, time.Until(a.touch.nextRepeat))
+	}
+	return max(timeout, time.Millisecond)
+}
+
+// tickBlink 到达闪烁间隔时切换光标和闪烁文字的显示状态
+func (a *App) tickBlink() {
+	if time.Since(a.lastBlink) < blinkInterval {
+		return
+	}
+	a.blinkOn = !a.blinkOn
+	a.lastBlink = time.Now()
+	a.screen.MarkBlinkDirty()
+	a.redraw = true
+}
+
+// ensureTermTexture 返回保存终端内容的目标纹理，尺寸变化时重新创建并标记全部重画
+// 渲染器不支持目标纹理时返回 nil，此时每帧直接重画所有行
+func (a *App) ensureTermTexture(width, height int32) *sdl.Texture {
+	if !a.renderer.RenderTargetSupported() {
+		return nil
+	}
+	if a.termTexture != nil {
+		_, _, w, h, err := a.termTexture.Query()
+		if err == nil && w == width && h == height {
+			return a.termTexture
+		}
+		a.termTexture.Destroy()
+		a.termTexture = nil
+	}
+	texture, err := a.renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGB888), sdl.TEXTUREACCESS_TARGET, width, height)
+	if err != nil {
+		return nil
+	}
+	a.termTexture = texture
+	a.screen.MarkAllDirty()
+	return texture
+}
+
+// resetRenderTargets 渲染目标或设备重置后纹理内容丢失，需要重建缓存并全部重画
+func (a *App) resetRenderTargets(deviceReset bool) {
+	if deviceReset {
+		a.glyphs.reset()
+		if a.termTexture != nil {
+			a.termTexture.Destroy()
+			a.termTexture = nil
+		}
+	}
+	a.screen.MarkAllDirty()
+	a.redraw = true
+}
+
+// render 绘制一帧
+func (a *App) render() {
+	a.renderer.SetDrawColor(0, 0, 0, 255)
+	a.renderer.Clear()
+
+	a.renderTerminal()
+	a.renderPreedit()
+	a.renderScrollIndicator()
+	a.renderSearchBar()
+	a.renderTitleBar()
+	a.renderExitBanner()
+	a.renderKeyboard()
+
+	a.renderer.Present()
+	a.redraw = false
+	a.lastFrame = time.Now()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"container/list"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+const (
+	atlasPageSize = 512 // 每张图集纹理的边长（像素）
+	maxAtlasPages = 4   // 图集纹理数量上限，槽位用完后按 LRU 淘汰字形
+)
+
+// glyphKey 字形缓存的键：字符（或字素）和字体样式，颜色不参与缓存
+type glyphKey struct {
+	text  string
+	style int
+}
+
+// glyphEntry 缓存中的一个字形
+type glyphEntry struct {
+	key  glyphKey
+	slot int   // 在图集中的槽位编号
+	w, h int32 // 字形的实际尺寸
+}
+
+// glyphCache 字形纹理缓存
+// 字形以白色渲染到图集纹理的固定大小槽位中，绘制时通过 SetColorMod 着色，
+// 稳定状态下每帧不需要任何 TTF 渲染
+type glyphCache struct {
+	renderer     *sdl.Renderer
+	font         *ttf.Font
+	slotW, slotH int32
+	slotsPerRow  int
+	slotsPerPage int
+	pages        []*sdl.Texture
+	entries      map[glyphKey]*list.Element
+	lru          *list.List // 最近使用的字形在前
+	nextSlot     int        // 下一个从未使用过的槽位
+}
+
+// newGlyphCache 创建字形缓存，槽位能容纳一个宽字符（两个单元格）外加斜体的少量溢出
+func newGlyphCache(renderer *sdl.Renderer, font *ttf.Font, cellWidth, cellHeight int) *glyphCache {
+	slotW := int32(min(atlasPageSize, 2*cellWidth+cellWidth/2))
+	slotH := int32(min(atlasPageSize, max(cellHeight, font.Height())))
+	slotsPerRow := atlasPageSize / int(slotW)
+	return &glyphCache{
+		renderer:     renderer,
+		font:         font,
+		slotW:        slotW,
+		slotH:        slotH,
+		slotsPerRow:  slotsPerRow,
+		slotsPerPage: slotsPerRow * (atlasPageSize / int(slotH)),
+		entries:      make(map[glyphKey]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+// draw 以指定颜色绘制一个字形，返回字形宽度（用于连续绘制字符串）
+func (c *glyphCache) draw(text string, style int, x, y int32, r, g, b uint8) int32 {
+	entry := c.lookup(glyphKey{text: text, style: style})
+	if entry == nil {
+		return 0
+	}
+	page, src := c.slotRect(entry.slot, entry.w, entry.h)
+	page.SetColorMod(r, g, b)
+	c.renderer.Copy(page, &src, &sdl.Rect{X: x, Y: y, W: entry.w, H: entry.h})
+	return entry.w
+}
+
+// drawString 逐字符绘制字符串，每个字符单独缓存，避免整串文字占用图集
+func (c *glyphCache) drawString(text string, style int, x, y int32, r, g, b uint8) {
+	for len(text) > 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		x += c.draw(text[:size], style, x, y, r, g, b)
+		text = text[size:]
+	}
+}
+
+// lookup 查找字形，未命中时渲染并写入图集
+func (c *glyphCache) lookup(key glyphKey) *glyphEntry {
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*glyphEntry)
+	}
+
+	// SetStyle 会清空 TTF 内部缓存，只在未命中且样式不同时切换
+	if c.font.GetStyle() != key.style {
+		c.font.SetStyle(key.style)
+	}
+	surface, err := c.font.RenderUTF8Blended(key.text, sdl.Color{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		return nil
+	}
+	defer surface.Free()
+	argb, err := surface.ConvertFormat(uint32(sdl.PIXELFORMAT_ARGB8888), 0)
+	if err != nil {
+		return nil
+	}
+	defer argb.Free()
+
+	slot, ok := c.allocSlot()
+	if !ok {
+		return nil
+	}
+	// 超出槽位的部分被裁掉
+	entry := &glyphEntry{key: key, slot: slot, w: min(argb.W, c.slotW), h: min(argb.H, c.slotH)}
+	page, dst := c.slotRect(slot, entry.w, entry.h)
+	pixels := argb.Pixels()
+	if len(pixels) > 0 {
+		page.Update(&dst, unsafe.Pointer(&pixels[0]), int(argb.Pitch))
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	return entry
+}
+
+// allocSlot 分配一个槽位：优先使用新槽位（必要时创建新的图集纹理），用完后淘汰最久未使用的字形
+func (c *glyphCache) allocSlot() (int, bool) {
+	if c.nextSlot < c.slotsPerPage*maxAtlasPages {
+		if c.nextSlot/c.slotsPerPage >= len(c.pages) {
+			page, err := c.renderer.CreateTexture(uint32(sdl.PIXELFORMAT_ARGB8888), sdl.TEXTUREACCESS_STATIC, atlasPageSize, atlasPageSize)
+			if err != nil {
+				return 0, false
+			}
+			page.SetBlendMode(sdl.BLENDMODE_BLEND)
+			c.pages = append(c.pages, page)
+		}
+		slot := c.nextSlot
+		c.nextSlot++
+		return slot, true
+	}
+
+	elem := c.lru.Back()
+	if elem == nil {
+		return 0, false
+	}
+	entry := c.lru.Remove(elem).(*glyphEntry)
+	delete(c.entries, entry.key)
+	return entry.slot, true
+}
+
+// slotRect 返回槽位所在的图集纹理和槽位左上角开始的矩形
+func (c *glyphCache) slotRect(slot int, w, h int32) (*sdl.Texture, sdl.Rect) {
+	page := c.pages[slot/c.slotsPerPage]
+	index := slot % c.slotsPerPage
+	return page, sdl.Rect{
+		X: int32(index%c.slotsPerRow) * c.slotW,
+		Y: int32(index/c.slotsPerRow) * c.slotH,
+		W: w,
+		H: h,
+	}
+}
+
+// reset 清空缓存并释放图集纹理，渲染设备重置后纹理内容会丢失
+func (c *glyphCache) reset() {
+	for _, page := range c.pages {
+		page.Destroy()
+	}
+	c.pages = nil
+	c.entries = make(map[glyphKey]*list.Element)
+	c.lru.Init()
+	c.nextSlot = 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"main/vt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// imeState 输入法的状态
+// 可打印的文字由 TextInputEvent 提供，输入法组字期间的预编辑文本由 TextEditingEvent 提供
+type imeState struct {
+	preedit   string   // 正在组字的预编辑文本，画在光标处，确认后由 TextInputEvent 送出
+	cursor    int      // 预编辑文本中的光标位置（字符数）
+	suppress  bool     // 刚按组合键发送过文字键，忽略同一批事件中随后产生的文字
+	inputRect sdl.Rect // 上次告知输入法的光标位置，用于放置候选窗口
+}
+
+// handleTextInput 输入法或键盘布局产生的文字：搜索时编辑查询，否则原样发送给终端
+func (a *App) handleTextInput(e *sdl.TextInputEvent) {
+	text := e.GetText()
+	a.ime.preedit, a.ime.cursor = "", 0
+	if a.ime.suppress {
+		a.ime.suppress = false
+		return
+	}
+	if text == "" || a.terminal.pty == nil || a.childExited {
+		return
+	}
+	if a.search.active {
+		a.editSearch(text)
+		return
+	}
+	a.sendInput(text)
+}
+
+// handleTextEditing 更新输入法的预编辑文本
+func (a *App) handleTextEditing(e *sdl.TextEditingEvent) {
+	a.ime.preedit = e.GetText()
+	a.ime.cursor = int(e.Start)
+}
+
+// isTextKey 按键是否产生可打印的文字，SDL 中这类按键的键码就是当前键盘布局下不带 Shift 的字符
+func isTextKey(key sdl.Keycode) bool {
+	return key >= sdl.K_SPACE && key&sdl.K_SCANCODE_MASK == 0 && key != sdl.K_DELETE
+}
+
+// updateTextInputRect 光标移动后告知输入法新的位置，使候选窗口跟随光标
+func (a *App) updateTextInputRect() {
+	s := &a.screen
+	rect := sdl.Rect{
+		X: int32(s.CursorX * a.Cfg.char_width),
+		Y: int32(a.Cfg.title_bar_height + s.CursorY*a.Cfg.char_height),
+		W: int32(a.Cfg.char_width),
+		H: int32(a.Cfg.char_height),
+	}
+	if rect != a.ime.inputRect {
+		a.ime.inputRect = rect
+		sdl.SetTextInputRect(&rect)
+	}
+}
+
+// renderPreedit 在光标处绘制预编辑文本，带下划线并显示输入法中的光标位置
+// 视图滚动到历史时光标不可见，不绘制
+func (a *App) renderPreedit() {
+	s := &a.screen
+	if s.ViewOffset > 0 || s.CursorY >= len(s.Rows) {
+		return
+	}
+	a.updateTextInputRect()
+	if a.ime.preedit == "" {
+		return
+	}
+	cols := len(s.Rows[s.CursorY])
+	width := vt.StringWidth(a.ime.preedit)
+	// 放不下时向左移动，避免超出窗口
+	startX := max(0, min(s.CursorX, cols-width))
+	x := int32(startX * a.Cfg.char_width)
+	y := int32(a.Cfg.title_bar_height + s.CursorY*a.Cfg.char_height)
+	w := int32(width * a.Cfg.char_width)
+	h := int32(a.Cfg.char_height)
+
+	a.renderer.SetDrawColor(60, 60, 60, 255)
+	a.renderer.FillRect(&sdl.Rect{X: x, Y: y, W: w, H: h})
+	a.renderText(a.ime.preedit, x, y+int32(a.Cfg.glyph_offset_y), 255, 255, 255)
+	a.renderer.SetDrawColor(255, 255, 255, 255)
+	underlineY := y + a.Cfg.underlineOffset()
+	a.renderer.DrawLine(x, underlineY, x+w-1, underlineY)
+
+	runes := []rune(a.ime.preedit)
+	caretX := x + int32(vt.StringWidth(string(runes[:min(a.ime.cursor, len(runes))]))*a.Cfg.char_width)
+	a.renderer.SetDrawColor(0, 255, 0, 255)
+	a.renderer.FillRect(&sdl.Rect{X: caretX, Y: y, W: 3, H: h})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,31 +11,48 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-	"unicode/utf8"
 
 	"github.com/creack/pty"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 	"golang.org/x/term"
+
+	"main/vt"
 )
 
 type Config struct {
-	Window_Width    int     `json:"window_width"`
-	Window_Height   int     `json:"window_height"`
-	TerminalRatio   float64 `json:"terminal_ratio"`
-	KeyboardRatio   float64 `json:"keyboard_ratio"`
-	Font            string  `json:"font"`
-	FontSize        int     `json:"font_size"`
-	StartCmd        string  `json:"start_cmd"`
-	terminal_height int
-	keyboard_height int
-	char_width      int
-	char_height     int
+	Window_Width     int               `json:"window_width"`
+	Window_Height    int               `json:"window_height"`
+	TerminalRatio    float64           `json:"terminal_ratio"`
+	KeyboardRatio    float64           `json:"keyboard_ratio"`
+	Font             string            `json:"font"`
+	FontSize         int               `json:"font_size"`
+	StartCmd         string            `json:"start_cmd"`        // 启动命令，按 shell 规则切分，优先于 shell/args
+	Shell            string            `json:"shell"`            // 启动的 shell，为空时使用 $SHELL，再退回 bash
+	Args             []string          `json:"args"`             // shell 的参数
+	Cwd              string            `json:"cwd"`              // 工作目录，支持 ~
+	Env              map[string]string `json:"env"`              // 额外的环境变量
+	Locale           string            `json:"locale"`           // LANG/LC_ALL，默认 zh_CN.UTF-8
+	ShowTitleBar     bool              `json:"show_title_bar"`   // 在终端上方显示标题栏（全屏设备看不到窗口标题）
+	OnExit           string            `json:"on_exit"`          // 子进程退出后的行为：wait（默认）、close、restart
+	MaxFPS           int               `json:"max_fps"`          // 帧率上限，为 0 时使用 60；只有内容变化时才会绘制
+	CellWidth        int               `json:"cell_width"`       // 单元格宽度（像素），为 0 时取字体中 "M" 的宽度
+	CellHeight       int               `json:"cell_height"`      // 单元格高度（像素），为 0 时取字体的行距
+	LineSpacing      int               `json:"line_spacing"`     // 在字体行距基础上额外增加的行间距（像素），可为负
+	ScrollbackLines  int               `json:"scrollback_lines"` // 历史行数，默认 1000；0 不保存历史，-1 不限制（上限 100000 行）
+	ScrollOnInput    bool              `json:"scroll_on_input"`  // 查看历史时按键是否回到最新的内容，默认开启
+	terminal_height  int
+	keyboard_height  int
+	char_width       int
+	char_height      int
+	char_ascent      int
+	glyph_offset_y   int
+	title_bar_height int
 }
 
 const (
@@ -63,50 +81,54 @@ var (
 // CHAR_HEIGHT int = 24 // 调整字符高度适应20号字体
 )
 
-type Cell struct {
-	char  string
-	width int // 字符显示宽度：1为半角，2为全角
-}
-
+// Terminal 将终端模拟器与 pty 和子进程连接起来
 type Terminal struct {
-	cmd           *exec.Cmd
-	pty           *os.File
-	oldState      *term.State
-	output        []string
-	maxLines      int
-	mutex         sync.RWMutex
-	cursorX       int
-	cursorY       int
-	screenBuffer  [][]Cell
-	screenWidth   int
-	screenHeight  int
-	escapeBuffer  strings.Builder
-	inEscape      bool
-	lastBlink     time.Time
-	cursorVisible bool
-	utf8Buffer    []byte
-	// 滚动
-	totalBuffer [][]Cell // 完整的缓冲区，保存所有历史内容
-	totalLines  int      // 总行数
-	viewOffset  int      // 视图偏移量（从总缓冲区的哪一行开始显示）
-	maxHistory  int      // 最大历史行数
+	*vt.Emulator
+	cmd      *exec.Cmd
+	pty      *os.File
+	done     chan struct{} // 子进程退出后关闭
+	wakeup   chan struct{} // 有新输出时通知界面
+	mutex    sync.Mutex    // 保护子进程的退出状态
+	exited   bool
+	exitCode int
+	oldState *term.State
 }
 
 type App struct {
 	// 配置
 	Cfg *Config
 	// 渲染
-	window   *sdl.Window
-	renderer *sdl.Renderer
-	font     *ttf.Font
+	window      *sdl.Window
+	renderer    *sdl.Renderer
+	font        *ttf.Font
+	glyphs      *glyphCache  // 字形纹理缓存
+	fontStyle   int          // 当前绘制文字使用的字体样式（粗体/斜体）
+	termTexture *sdl.Texture // 保存终端内容的目标纹理，每帧只重画有变化的行
+	screen      vt.Snapshot
+	wakeupEvent uint32    // 终端有新输出时推送的 SDL 用户事件类型
+	redraw      bool      // 终端以外的界面状态有变化，需要重新绘制
+	lastFrame   time.Time // 上一帧的绘制时间，用于帧率限制
+	blinkOn     bool      // 光标和闪烁文字当前是否显示
+	lastBlink   time.Time
 	// 终端
-	terminal *Terminal
-	running  bool
+	terminal  *Terminal
+	running   atomic.Bool // 信号处理的 goroutine 也会修改
+	title     string      // 当前显示的窗口标题
+	search    searchState // 历史搜索
+	selection selection   // 文本选择
+	ime       imeState    // 输入法
+	mouse     mouseReport // 鼠标报告
+	touch     touchState  // 触摸屏
+	// 子进程状态
+	childExited bool      // 子进程已退出，正在显示退出提示
+	startTime   time.Time // 子进程启动时间
 	// 虚拟键盘
 	selectedRow int
 	selectedCol int
 	capsLock    bool
 	keyBoards   [][]string
+	// 虚拟键盘是否显示，隐藏时终端占满窗口
+	keyboardVisible bool
 	// 物理键盘
 	keyMaps *KeyMaps
 	// 物理手柄
@@ -119,12 +141,8 @@ type App struct {
 }
 
 type KeyMaps struct {
-	// Ctrl组合键映射
-	ctrlKeys map[sdl.Keycode]string
-	// Alt组合键映射
-	altKeys map[sdl.Keycode]string
-	// 功能键映射
-	functionKeys map[sdl.Keycode]string
+	// 功能键、编辑键和小键盘，按终端模式由 vt.EncodeKey 编码
+	specialKeys map[sdl.Keycode]vt.Key
 	// 普通字符映射 (不带Shift)
 	normalKeys map[sdl.Keycode]string
 	// Shift字符映射
@@ -133,57 +151,37 @@ type KeyMaps struct {
 
 func initKeyMaps() *KeyMaps {
 	return &KeyMaps{
-		ctrlKeys: map[sdl.Keycode]string{
-			sdl.K_c: "\x03",    // Ctrl+C (中断)
-			sdl.K_d: "\x04",    // Ctrl+D (EOF)
-			sdl.K_z: "\x1a",    // Ctrl+Z (挂起)
-			sdl.K_l: "clear\n", // Ctrl+L (清屏)
-			sdl.K_a: "\x01",    // Ctrl+A (行首)
-			sdl.K_e: "\x05",    // Ctrl+E (行尾)
-			sdl.K_u: "\x15",    // Ctrl+U (删除到行首)
-			sdl.K_k: "\x0b",    // Ctrl+K (删除到行尾)
-			sdl.K_w: "\x17",    // Ctrl+W (删除前一个单词)
-			sdl.K_r: "\x12",    // Ctrl+R (搜索历史)
-		},
-		altKeys: map[sdl.Keycode]string{
-			sdl.K_b: "\x1bb", // Alt+B (后退一个单词)
-			sdl.K_f: "\x1bf", // Alt+F (前进一个单词)
-			sdl.K_d: "\x1bd", // Alt+D (删除下一个单词)
-		},
-		functionKeys: map[sdl.Keycode]string{
+		specialKeys: map[sdl.Keycode]vt.Key{
 			// 基本控制键
-			sdl.K_RETURN:    "\n",
-			sdl.K_KP_ENTER:  "\n",
-			sdl.K_BACKSPACE: "\b",
-			sdl.K_DELETE:    "\x1b[3~",
-			sdl.K_TAB:       "\t",
-			sdl.K_ESCAPE:    "\x1b",
+			sdl.K_RETURN:    vt.KeyEnter,
+			sdl.K_BACKSPACE: vt.KeyBackspace,
+			sdl.K_TAB:       vt.KeyTab,
+			sdl.K_ESCAPE:    vt.KeyEscape,
 			// 方向键
-			sdl.K_UP:    "\x1b[A",
-			sdl.K_DOWN:  "\x1b[B",
-			sdl.K_RIGHT: "\x1b[C",
-			sdl.K_LEFT:  "\x1b[D",
+			sdl.K_UP:    vt.KeyUp,
+			sdl.K_DOWN:  vt.KeyDown,
+			sdl.K_RIGHT: vt.KeyRight,
+			sdl.K_LEFT:  vt.KeyLeft,
 
 			// Home/End/Page键
-			sdl.K_HOME:     "\x1b[H",
-			sdl.K_END:      "\x1b[F",
-			sdl.K_PAGEUP:   "\x1b[5~",
-			sdl.K_PAGEDOWN: "\x1b[6~",
-			sdl.K_INSERT:   "\x1b[2~",
+			sdl.K_HOME:     vt.KeyHome,
+			sdl.K_END:      vt.KeyEnd,
+			sdl.K_PAGEUP:   vt.KeyPageUp,
+			sdl.K_PAGEDOWN: vt.KeyPageDown,
+			sdl.K_INSERT:   vt.KeyInsert,
+			sdl.K_DELETE:   vt.KeyDelete,
 
 			// F功能键
-			sdl.K_F1:  "\x1bOP",
-			sdl.K_F2:  "\x1bOQ",
-			sdl.K_F3:  "\x1bOR",
-			sdl.K_F4:  "\x1bOS",
-			sdl.K_F5:  "\x1b[15~",
-			sdl.K_F6:  "\x1b[17~",
-			sdl.K_F7:  "\x1b[18~",
-			sdl.K_F8:  "\x1b[19~",
-			sdl.K_F9:  "\x1b[20~",
-			sdl.K_F10: "\x1b[21~",
-			sdl.K_F11: "\x1b[23~",
-			sdl.K_F12: "\x1b[24~",
+			sdl.K_F1: vt.KeyF1, sdl.K_F2: vt.KeyF2, sdl.K_F3: vt.KeyF3, sdl.K_F4: vt.KeyF4,
+			sdl.K_F5: vt.KeyF5, sdl.K_F6: vt.KeyF6, sdl.K_F7: vt.KeyF7, sdl.K_F8: vt.KeyF8,
+			sdl.K_F9: vt.KeyF9, sdl.K_F10: vt.KeyF10, sdl.K_F11: vt.KeyF11, sdl.K_F12: vt.KeyF12,
+
+			// 小键盘
+			sdl.K_KP_0: vt.KeyKP0, sdl.K_KP_1: vt.KeyKP1, sdl.K_KP_2: vt.KeyKP2, sdl.K_KP_3: vt.KeyKP3,
+			sdl.K_KP_4: vt.KeyKP4, sdl.K_KP_5: vt.KeyKP5, sdl.K_KP_6: vt.KeyKP6, sdl.K_KP_7: vt.KeyKP7,
+			sdl.K_KP_8: vt.KeyKP8, sdl.K_KP_9: vt.KeyKP9, sdl.K_KP_PERIOD: vt.KeyKPDecimal,
+			sdl.K_KP_DIVIDE: vt.KeyKPDivide, sdl.K_KP_MULTIPLY: vt.KeyKPMultiply,
+			sdl.K_KP_MINUS: vt.KeyKPMinus, sdl.K_KP_PLUS: vt.KeyKPPlus, sdl.K_KP_ENTER: vt.KeyKPEnter,
 		},
 		normalKeys: map[sdl.Keycode]string{
 			// 数字
@@ -245,68 +243,61 @@ func initKeyMaps() *KeyMaps {
 	}
 }
 
-// getCharWidth 返回字符的显示宽度
-func getCharWidth(char string) int {
-	if len(char) == 0 {
-		return 0
+// keyEvent 将 SDL 按键转换为 vt.KeyEvent，不支持的按键返回 false
+// 不带 Ctrl/Alt 的文字键由 TextInputEvent 输入，这里返回 false；只有没有开启文字输入时才查表作为后备
+// 文字键的 Code 为不带 Shift 的字符，Text 为 Shift 和大写锁定 (只影响字母) 作用后的字符
+func (k *KeyMaps) keyEvent(key sdl.Keycode, mod uint16) (vt.KeyEvent, bool) {
+	var ev vt.KeyEvent
+	// AltGr 用于输入文字（Windows 上报告为左 Ctrl + 右 Alt），不作为修饰键
+	if mod&sdl.KMOD_MODE != 0 || mod&sdl.KMOD_LCTRL != 0 && mod&sdl.KMOD_RALT != 0 {
+		mod &^= sdl.KMOD_CTRL | sdl.KMOD_ALT
 	}
-
-	// 获取第一个rune
-	r, _ := utf8.DecodeRuneInString(char)
-
-	// 简单的字符宽度判断
-	// 中文字符范围
-	if r >= 0x4e00 && r <= 0x9fff {
-		return 2
+	if mod&sdl.KMOD_SHIFT != 0 {
+		ev.Mod |= vt.ModShift
 	}
-	// 中文标点符号
-	if r >= 0x3000 && r <= 0x303f {
-		return 2
+	if mod&sdl.KMOD_ALT != 0 {
+		ev.Mod |= vt.ModAlt
 	}
-	// 全角字符
-	if r >= 0xff00 && r <= 0xffef {
-		return 2
-	}
-	// 其他一些常见的宽字符
-	if r >= 0x1100 && r <= 0x11ff { // 韩文字母
-		return 2
-	}
-	if r >= 0x2e80 && r <= 0x2eff { // CJK 部首补充
-		return 2
-	}
-	if r >= 0x2f00 && r <= 0x2fdf { // 康熙部首
-		return 2
-	}
-	if r >= 0x3100 && r <= 0x312f { // 注音符号
-		return 2
-	}
-	if r >= 0x3200 && r <= 0x32ff { // 带圈字符
-		return 2
+	if mod&sdl.KMOD_CTRL != 0 {
+		ev.Mod |= vt.ModCtrl
 	}
-	if r >= 0x3400 && r <= 0x4dbf { // CJK 扩展A
-		return 2
+	if special, exists := k.specialKeys[key]; exists {
+		ev.Key = special
+		return ev, true
 	}
-	if r >= 0xac00 && r <= 0xd7af { // 韩文音节
-		return 2
+	if ev.Mod&(vt.ModCtrl|vt.ModAlt) == 0 && sdl.IsTextInputActive() {
+		return ev, false
 	}
-	if r >= 0xf900 && r <= 0xfaff { // CJK 兼容汉字
-		return 2
+	char, exists := k.normalKeys[key]
+	if !exists {
+		if !isTextKey(key) {
+			return ev, false
+		}
+		// 表中没有的按键（非美式布局）使用键码本身的字符
+		char = string(rune(key))
+	}
+	ev.Code, ev.Text = []rune(char)[0], char
+	shifted := ev.Mod&vt.ModShift != 0
+	if shifted || mod&sdl.KMOD_CAPS != 0 && key >= sdl.K_a && key <= sdl.K_z {
+		if upper, exists := k.shiftKeys[key]; exists {
+			ev.Text = upper
+		}
 	}
-
-	return 1 // 默认半角
+	return ev, true
 }
 
-func NewTerminal(screenWidth, screenHeight int) (*Terminal, error) {
-	cmd := exec.Command("bash", "--norc", "--noprofile", "-i")
-	maxHistory := 1000 // 保存1000行历史
+// sendKey 按终端当前的模式编码按键并发送
+func (a *App) sendKey(ev vt.KeyEvent) {
+	if sequence := vt.EncodeKey(ev, a.terminal.Modes()); sequence != "" {
+		a.sendInput(sequence)
+	}
+}
 
-	cmd.Env = append(os.Environ(),
-		"TERM=xterm-256color",
-		"LANG=zh_CN.UTF-8",
-		"LC_ALL=zh_CN.UTF-8",
-		fmt.Sprintf("COLUMNS=%d", screenWidth),
-		fmt.Sprintf("LINES=%d", screenHeight),
-	)
+func NewTerminal(cfg *Config, screenWidth, screenHeight int) (*Terminal, error) {
+	cmd, err := buildCommand(cfg, screenWidth, screenHeight)
+	if err != nil {
+		return nil, err
+	}
 
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
@@ -320,38 +311,14 @@ func NewTerminal(screenWidth, screenHeight int) (*Terminal, error) {
 	}
 
 	terminal := &Terminal{
-		cmd:           cmd,
-		pty:           ptmx,
-		oldState:      oldState,
-		output:        make([]string, 0),
-		maxLines:      screenHeight,
-		screenWidth:   screenWidth,
-		screenHeight:  screenHeight,
-		screenBuffer:  make([][]Cell, screenHeight),
-		totalBuffer:   make([][]Cell, maxHistory),
-		totalLines:    0,
-		viewOffset:    0,
-		maxHistory:    maxHistory,
-		lastBlink:     time.Now(),
-		cursorVisible: true,
-		utf8Buffer:    make([]byte, 0, 4),
-	}
-
-	// 初始化屏幕缓冲区
-	for i := range terminal.screenBuffer {
-		terminal.screenBuffer[i] = make([]Cell, screenWidth)
-		for j := range terminal.screenBuffer[i] {
-			terminal.screenBuffer[i][j] = Cell{char: " ", width: 1}
-		}
-	}
-
-	// 初始化总缓冲区
-	for i := range terminal.totalBuffer {
-		terminal.totalBuffer[i] = make([]Cell, screenWidth)
-		for j := range terminal.totalBuffer[i] {
-			terminal.totalBuffer[i][j] = Cell{char: " ", width: 1}
-		}
+		Emulator: vt.NewEmulator(screenWidth, screenHeight),
+		cmd:      cmd,
+		pty:      ptmx,
+		done:     make(chan struct{}),
+		wakeup:   make(chan struct{}, 1),
+		oldState: oldState,
 	}
+	terminal.SetScrollback(cfg.ScrollbackLines)
 
 	winSize := &pty.Winsize{
 		Rows: uint16(screenHeight),
@@ -362,431 +329,29 @@ func NewTerminal(screenWidth, screenHeight int) (*Terminal, error) {
 	}
 
 	go terminal.readOutput()
+	go terminal.waitChild()
 	return terminal, nil
 }
 
-// 修改 scrollUp 方法，同时更新总缓冲区
-func (t *Terminal) scrollUp() {
-	// 如果总缓冲区已满，移除最老的一行
-	if t.totalLines >= t.maxHistory {
-		// 向上移动所有行
-		for y := 0; y < t.maxHistory-1; y++ {
-			copy(t.totalBuffer[y], t.totalBuffer[y+1])
-		}
-		// 清空最后一行
-		for x := 0; x < t.screenWidth; x++ {
-			t.totalBuffer[t.maxHistory-1][x] = Cell{char: " ", width: 1}
-		}
-	} else {
-		t.totalLines++
-	}
-
-	// 将当前屏幕的第一行保存到总缓冲区
-	targetLine := min(t.totalLines-1, t.maxHistory-1)
-	if targetLine >= 0 {
-		copy(t.totalBuffer[targetLine], t.screenBuffer[0])
-	}
-
-	// 屏幕缓冲区向上滚动
-	for y := 0; y < t.screenHeight-1; y++ {
-		copy(t.screenBuffer[y], t.screenBuffer[y+1])
-	}
-	for x := 0; x < t.screenWidth; x++ {
-		t.screenBuffer[t.screenHeight-1][x] = Cell{char: " ", width: 1}
-	}
-	t.cursorY = t.screenHeight - 1
-
-	// 自动调整视图偏移量，保持显示最新内容
-	maxOffset := max(0, t.totalLines-1)
-	if t.viewOffset > maxOffset {
-		t.viewOffset = maxOffset
-	}
-}
-
-// 添加滚动控制方法
-func (t *Terminal) ScrollView(delta int) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
-	oldOffset := t.viewOffset
-	t.viewOffset += delta
-
-	// 限制滚动范围
-	maxOffset := max(0, t.totalLines-1)
-	if t.viewOffset < 0 {
-		t.viewOffset = 0
-	} else if t.viewOffset > maxOffset {
-		t.viewOffset = maxOffset
-	}
-
-	// 如果偏移量发生变化，更新显示
-	if t.viewOffset != oldOffset {
-		t.updateDisplayBuffer()
-	}
-}
-
-// 添加更新显示缓冲区的方法
-func (t *Terminal) updateDisplayBuffer() {
-	// 如果没有历史内容或者显示最新内容，直接返回
-	if t.viewOffset == 0 || t.totalLines == 0 {
-		return
-	}
-
-	// 计算要显示的历史内容范围
-	startLine := max(0, t.totalLines-t.viewOffset-t.screenHeight)
-
-	// 更新屏幕缓冲区，显示历史内容
-	for y := 0; y < t.screenHeight; y++ {
-		historyLine := startLine + y
-		if historyLine >= 0 && historyLine < t.totalLines && historyLine < t.maxHistory {
-			copy(t.screenBuffer[y], t.totalBuffer[historyLine])
-		} else {
-			// 如果没有历史内容，显示空行
-			for x := 0; x < t.screenWidth; x++ {
-				t.screenBuffer[y][x] = Cell{char: " ", width: 1}
-			}
-		}
-	}
-}
-
 func (t *Terminal) readOutput() {
 	buf := make([]byte, 4096)
 	for {
 		n, err := t.pty.Read(buf)
 		if err != nil {
-			if err != io.EOF {
+			// 子进程退出后读取 pty 会返回 EIO，重启时 pty 被关闭会返回 ErrClosed，都属于正常结束
+			if err != io.EOF && !errors.Is(err, syscall.EIO) && !errors.Is(err, os.ErrClosed) {
 				fmt.Printf("读取终端输出错误: %v\n", err)
 			}
 			break
 		}
-		for i := 0; i < n; i++ {
-			t.processByte(buf[i])
-		}
-		t.updateOutput()
-	}
-}
-
-func (t *Terminal) processByte(b byte) {
-	// 处理UTF-8多字节字符
-	t.utf8Buffer = append(t.utf8Buffer, b)
-
-	// 检查是否是完整的UTF-8字符
-	if utf8.Valid(t.utf8Buffer) {
-		char := string(t.utf8Buffer)
-		t.utf8Buffer = t.utf8Buffer[:0] // 清空缓冲区
-
-		// 处理完整的UTF-8字符
-		t.processChar(char)
-		return
-	}
-
-	// 如果缓冲区太长，说明不是有效的UTF-8序列，处理单个字节
-	if len(t.utf8Buffer) > 4 {
-		// 处理第一个字节作为单个字符
-		firstByte := t.utf8Buffer[0]
-		t.utf8Buffer = t.utf8Buffer[1:]
-		t.processChar(string(firstByte))
-	}
-}
-
-func (t *Terminal) processChar(char string) {
-	if len(char) == 1 {
-		b := char[0]
-		if t.inEscape {
-			t.escapeBuffer.WriteByte(b)
-			if t.isEscapeComplete(b) {
-				t.processEscapeSequence(t.escapeBuffer.String())
-				t.escapeBuffer.Reset()
-				t.inEscape = false
-			}
-			return
-		}
-
-		switch b {
-		case 27: // ESC
-			t.inEscape = true
-			t.escapeBuffer.WriteByte(b)
-			return
-		case '\n':
-			t.cursorX = 0
-			t.cursorY++
-			if t.cursorY >= t.screenHeight {
-				t.scrollUp()
-			}
-			return
-		case '\b':
-			t.handleBackspace()
-			return
-		case '\r':
-			t.cursorX = 0
-			return
-		case '\t':
-			nextTab := ((t.cursorX / 8) + 1) * 8
-			for t.cursorX < nextTab && t.cursorX < t.screenWidth {
-				t.screenBuffer[t.cursorY][t.cursorX] = Cell{char: " ", width: 1}
-				t.cursorX++
-			}
-			return
-		}
-	}
-
-	// 处理可显示字符（包括UTF-8字符）
-	// 关键修复：明确排除退格字符和其他控制字符
-	if char != "\x00" && char != "\x7f" && char != "\b" && !t.inEscape && isPrintableChar(char) {
-		charWidth := getCharWidth(char)
-
-		// 检查是否有足够空间显示该字符
-		if t.cursorX+charWidth > t.screenWidth {
-			// 换行
-			t.cursorX = 0
-			t.cursorY++
-			if t.cursorY >= t.screenHeight {
-				t.scrollUp()
-			}
-		}
-
-		if t.cursorY < t.screenHeight {
-			// 写入字符
-			t.screenBuffer[t.cursorY][t.cursorX] = Cell{char: char, width: charWidth}
-
-			// 如果是宽字符，需要在下一个位置标记为占位符
-			if charWidth == 2 && t.cursorX+1 < t.screenWidth {
-				t.screenBuffer[t.cursorY][t.cursorX+1] = Cell{char: "", width: 0} // 占位符
-			}
-
-			t.cursorX += charWidth
-		}
-	}
-}
-
-func (t *Terminal) isEscapeComplete(b byte) bool {
-	escSeq := t.escapeBuffer.String()
-	if len(escSeq) < 2 {
-		return false
-	}
-
-	if strings.HasPrefix(escSeq, "\x1b[") {
-		return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
-	}
-
-	return len(escSeq) >= 2
-}
-
-func isPrintableChar(char string) bool {
-	if len(char) == 0 {
-		return false
-	}
-
-	// 对于单字节字符，检查ASCII范围
-	if len(char) == 1 {
-		b := char[0]
-		// 排除所有控制字符 (0-31 和 127)
-		if b < 32 || b == 127 {
-			return false
-		}
-		return true
-	}
-
-	// 对于多字节字符（UTF-8），获取第一个rune
-	r, _ := utf8.DecodeRuneInString(char)
-
-	// 排除Unicode控制字符
-	if r < 32 || (r >= 127 && r <= 159) {
-		return false
-	}
-
-	// 排除特殊的Unicode控制字符
-	if r == 0xFEFF || // BOM
-		(r >= 0x200B && r <= 0x200F) || // Zero-width spaces
-		(r >= 0x2028 && r <= 0x2029) || // Line/Paragraph separators
-		(r >= 0xE000 && r <= 0xF8FF) { // Private use area
-		return false
-	}
-
-	return true
-}
-
-func (t *Terminal) handleBackspace() {
-	if t.cursorX > 0 {
-		targetX := t.cursorX - 1
-
-		// 检查要删除位置的字符类型
-		if targetX >= 0 && targetX < t.screenWidth {
-			targetCell := t.screenBuffer[t.cursorY][targetX]
-			if int(targetCell.width) == 2 {
-				// 删除宽字符（如中文）
-				t.screenBuffer[t.cursorY][targetX] = Cell{char: " ", width: 1}
-				// 清除占位符
-				if targetX+1 < t.screenWidth {
-					t.screenBuffer[t.cursorY][targetX+1] = Cell{char: " ", width: 1}
-				}
-				t.cursorX = targetX
-			} else if targetCell.width == 0 {
-				// 这是宽字符的占位符，需要找到并删除真正的宽字符
-				realCharX := targetX - 1
-				if realCharX >= 0 && t.screenBuffer[t.cursorY][realCharX].width == 2 {
-					t.screenBuffer[t.cursorY][realCharX] = Cell{char: " ", width: 1}
-					t.screenBuffer[t.cursorY][targetX] = Cell{char: " ", width: 1}
-					t.cursorX = realCharX
-				} else {
-					// 异常情况，直接清除
-					t.screenBuffer[t.cursorY][targetX] = Cell{char: " ", width: 1}
-					t.cursorX = targetX
-				}
-			} else {
-				// 普通字符（width == 1）
-				t.screenBuffer[t.cursorY][targetX] = Cell{char: " ", width: 1}
-				t.cursorX = targetX
-			}
-		}
-	} else if t.cursorY > 0 {
-		// 光标在行首，移动到上一行末尾
-		t.cursorY--
-		// 找到上一行最后一个非空字符的位置
-		t.cursorX = t.screenWidth - 1
-		for t.cursorX >= 0 && (t.screenBuffer[t.cursorY][t.cursorX].char == " " || t.screenBuffer[t.cursorY][t.cursorX].width == 0) {
-			t.cursorX--
-		}
-		// 移动到该字符后面
-		if t.cursorX >= 0 {
-			if t.screenBuffer[t.cursorY][t.cursorX].width == 2 {
-				t.cursorX += 2 // 跳过宽字符
-			} else {
-				t.cursorX += 1
-			}
-		} else {
-			t.cursorX = 0 // 上一行为空
-		}
-		// 确保不超出边界
-		if t.cursorX >= t.screenWidth {
-			t.cursorX = t.screenWidth - 1
-		}
-	}
-	// 如果 cursorX == 0 && cursorY == 0，什么都不做
-}
-
-func (t *Terminal) processEscapeSequence(seq string) {
-	if len(seq) < 2 {
-		return
-	}
-
-	if strings.HasPrefix(seq, "\x1b[") {
-		params := seq[2 : len(seq)-1]
-		cmd := seq[len(seq)-1]
-
-		switch cmd {
-		case 'H', 'f':
-			t.setCursorPosition(params)
-		case 'A':
-			if n := t.parseNumber(params, 1); n > 0 {
-				t.cursorY = max(0, t.cursorY-n)
-			}
-		case 'B':
-			if n := t.parseNumber(params, 1); n > 0 {
-				t.cursorY = min(t.screenHeight-1, t.cursorY+n)
-			}
-		case 'C':
-			if n := t.parseNumber(params, 1); n > 0 {
-				t.cursorX = min(t.screenWidth-1, t.cursorX+n)
-			}
-		case 'D':
-			if n := t.parseNumber(params, 1); n > 0 {
-				t.cursorX = max(0, t.cursorX-n)
+		t.Write(buf[:n])
+		// 查询的应答（如 kitty 键盘协议）写回子进程
+		if replies := t.Replies(); len(replies) > 0 {
+			if _, err := t.pty.Write(replies); err != nil {
+				fmt.Printf("写入终端应答失败: %v\n", err)
 			}
-		case 'J':
-			t.clearScreen(params)
-		case 'K':
-			t.clearLine(params)
-		case 'm':
-			// 忽略颜色设置
 		}
-	}
-}
-
-func (t *Terminal) parseNumber(s string, defaultVal int) int {
-	if s == "" {
-		return defaultVal
-	}
-	if n, err := strconv.Atoi(s); err == nil {
-		return n
-	}
-	return defaultVal
-}
-
-func (t *Terminal) setCursorPosition(params string) {
-	parts := strings.Split(params, ";")
-	row := t.parseNumber(parts[0], 1) - 1
-	col := 0
-	if len(parts) > 1 {
-		col = t.parseNumber(parts[1], 1) - 1
-	}
-
-	t.cursorY = max(0, min(t.screenHeight-1, row))
-	t.cursorX = max(0, min(t.screenWidth-1, col))
-}
-
-func (t *Terminal) clearScreen(params string) {
-	n := t.parseNumber(params, 0)
-	switch n {
-	case 0:
-		for y := t.cursorY; y < t.screenHeight; y++ {
-			startX := 0
-			if y == t.cursorY {
-				startX = t.cursorX
-			}
-			for x := startX; x < t.screenWidth; x++ {
-				t.screenBuffer[y][x] = Cell{char: " ", width: 1}
-			}
-		}
-	case 1:
-		for y := 0; y <= t.cursorY; y++ {
-			endX := t.screenWidth
-			if y == t.cursorY {
-				endX = t.cursorX + 1
-			}
-			for x := 0; x < endX; x++ {
-				t.screenBuffer[y][x] = Cell{char: " ", width: 1}
-			}
-		}
-	case 2:
-		for y := 0; y < t.screenHeight; y++ {
-			for x := 0; x < t.screenWidth; x++ {
-				t.screenBuffer[y][x] = Cell{char: " ", width: 1}
-			}
-		}
-		t.cursorX = 0
-		t.cursorY = 0
-	}
-}
-
-func (t *Terminal) clearLine(params string) {
-	n := t.parseNumber(params, 0)
-	switch n {
-	case 0:
-		for x := t.cursorX; x < t.screenWidth; x++ {
-			t.screenBuffer[t.cursorY][x] = Cell{char: " ", width: 1}
-		}
-	case 1:
-		for x := 0; x <= t.cursorX; x++ {
-			t.screenBuffer[t.cursorY][x] = Cell{char: " ", width: 1}
-		}
-	case 2:
-		for x := 0; x < t.screenWidth; x++ {
-			t.screenBuffer[t.cursorY][x] = Cell{char: " ", width: 1}
-		}
-	}
-}
-
-func (t *Terminal) updateOutput() {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-	t.output = make([]string, t.screenHeight)
-	for y := 0; y < t.screenHeight; y++ {
-		var line strings.Builder
-		for x := 0; x < t.screenWidth; x++ {
-			line.WriteString(t.screenBuffer[y][x].char)
-		}
-		t.output[y] = line.String()
+		t.notify()
 	}
 }
 
@@ -797,7 +362,8 @@ func (t *Terminal) Close() {
 	if t.pty != nil {
 		t.pty.Close()
 	}
-	if t.cmd != nil {
+	// 子进程已经退出并被回收时不再发送信号
+	if _, exited := t.ExitStatus(); t.cmd != nil && t.cmd.Process != nil && !exited {
 		t.cmd.Process.Kill()
 	}
 }
@@ -805,7 +371,8 @@ func (t *Terminal) Close() {
 func NewApp() (*App, error) {
 	// step0. init cfg
 	cfg, err := func() (*Config, error) {
-		var config Config
+		// 未出现在配置文件中的字段保留这里的默认值
+		config := Config{ScrollbackLines: vt.DefaultScrollbackLines, ScrollOnInput: true}
 		pwd, err := os.Getwd()
 		if err != nil {
 			return &config, err
@@ -823,16 +390,15 @@ func NewApp() (*App, error) {
 			config.Window_Width = 640
 			config.Window_Height = 480
 		}
-		config.terminal_height = int(math.Round(float64(config.Window_Height) * config.TerminalRatio))
-		config.keyboard_height = int(math.Round(float64(config.Window_Height) * config.KeyboardRatio))
-		config.char_height = 24
-		config.char_width = 12
+		config.updateLayout(true)
 		return &config, nil
 	}()
 	if err != nil {
 		return nil, fmt.Errorf("init cfg failed: %v", err)
 	}
 	// step1. init sdl
+	// 触摸单独处理，不生成模拟的鼠标事件，避免同一次点击处理两次
+	sdl.SetHint(sdl.HINT_TOUCH_MOUSE_EVENTS, "0")
 	err = sdl.Init(sdl.INIT_VIDEO | sdl.INIT_GAMECONTROLLER)
 	if err != nil {
 		return nil, fmt.Errorf("init SDL2 failed: %v", err)
@@ -868,38 +434,54 @@ func NewApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
+	// 单元格尺寸由字体度量决定，标题栏高度依赖单元格高度
+	if err := cfg.applyFontMetrics(font); err != nil {
+		return nil, fmt.Errorf("measure font failed: %v", err)
+	}
+	if cfg.ShowTitleBar {
+		cfg.title_bar_height = cfg.char_height + 4
+	}
 	// step4. init window
 	window, err := sdl.CreateWindow(
-		"VTerm",
+		defaultWindowTitle,
 		sdl.WINDOWPOS_UNDEFINED,
 		sdl.WINDOWPOS_UNDEFINED,
 		int32(cfg.Window_Width),
 		int32(cfg.Window_Height),
-		sdl.WINDOW_SHOWN,
+		sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("init window failed: %v", err)
 	}
+	// 可打印的文字通过 TextInputEvent 接收，支持输入法和各种键盘布局
+	sdl.StartTextInput()
 	// step5. init renderer
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
 		return nil, fmt.Errorf("init SDL2 renderer failed: %v", err)
 	}
 	// step6. init termimal comphonent
-	terminal, err := NewTerminal(cfg.Window_Width/cfg.char_width, cfg.terminal_height/cfg.char_height)
+	cols, rows := cfg.terminalSize()
+	terminal, err := NewTerminal(cfg, cols, rows)
 	if err != nil {
 		return nil, fmt.Errorf("init terminal comphonent failed: %v", err)
 	}
 	// step7. build app
 	app := &App{
-		Cfg:         cfg,
-		window:      window,
-		renderer:    renderer,
-		font:        font,
-		terminal:    terminal,
-		running:     true,
-		selectedRow: 4,
-		selectedCol: 0,
+		Cfg:             cfg,
+		window:          window,
+		renderer:        renderer,
+		font:            font,
+		glyphs:          newGlyphCache(renderer, font, cfg.char_width, cfg.char_height),
+		terminal:        terminal,
+		wakeupEvent:     sdl.RegisterEvents(1),
+		redraw:          true,
+		blinkOn:         true,
+		lastBlink:       time.Now(),
+		startTime:       time.Now(),
+		selectedRow:     4,
+		keyboardVisible: true,
+		selectedCol:     0,
 		keyBoards: [][]string{
 			{"~", "!", "@", "#", "$", "%", "^", "&", "*", "?"},
 			{"-", "+", ",", ";", ":", "/", `\`, ".", "|", BTN_DEL},
@@ -908,25 +490,59 @@ func NewApp() (*App, error) {
 			{"q", "w", "e", "r", "t", "y", "u", "i", "o", "p"},
 			{"a", "s", "d", "f", "g", "h", "j", "k", "l", BTN_CAPS},
 			{"z", "x", "c", "v", "b", "n", "m", BTN_SPACE, BTN_CTRLC, BTN_ENTER},
+			// 搜索模式下 ⇥ 切换大小写敏感，CLEAR 切换正则，PRE/NXT 在匹配之间跳转
+			{BTN_TAB, BTN_CLEAR, BTN_HIS_PRE, BTN_HIS_NXT},
 		},
 		keyMaps:      initKeyMaps(),
+		mouse:        mouseReport{pressed: vt.MouseNoButton},
 		gamepad:      gamepad,
 		backPressed:  false,
 		startPressed: false,
 		lastAxisY:    0,
 		axisDeadzone: 8000, // 设置死区阈值
 	}
+	app.running.Store(true)
+	go app.forwardWakeups(terminal)
 	return app, nil
 }
 
 // 在 handleInput 函数中添加键盘事件处理
-func (a *App) handleInput() {
-	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+// handleInput 等待事件（最长 timeout），然后处理所有待处理的事件
+func (a *App) handleInput(timeout time.Duration) {
+	event := sdl.WaitEventTimeout(int(timeout.Milliseconds()))
+	// 按键和它产生的文字在同一批事件中，批次之间不再忽略文字
+	defer func() { a.ime.suppress = false }()
+	for ; event != nil; event = sdl.PollEvent() {
+		// 终端输出通知对应的行已标记为脏行，其余事件都可能改变界面
+		if e, ok := event.(*sdl.UserEvent); ok && e.Type == a.wakeupEvent {
+			continue
+		}
+		a.redraw = true
 		switch e := event.(type) {
 		case *sdl.QuitEvent:
-			a.running = false
+			a.running.Store(false)
+		case *sdl.WindowEvent:
+			if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+				a.Cfg.Window_Width = int(e.Data1)
+				a.Cfg.Window_Height = int(e.Data2)
+				a.relayout()
+			}
+		case *sdl.RenderEvent:
+			a.resetRenderTargets(e.Type == sdl.RENDER_DEVICE_RESET)
 		case *sdl.KeyboardEvent:
 			a.handleKeyboard(e)
+		case *sdl.TextInputEvent:
+			a.handleTextInput(e)
+		case *sdl.TextEditingEvent:
+			a.handleTextEditing(e)
+		case *sdl.MouseButtonEvent:
+			a.handleMouseButton(e)
+		case *sdl.MouseMotionEvent:
+			a.handleMouseMotion(e)
+		case *sdl.MouseWheelEvent:
+			a.handleMouseWheel(e)
+		case *sdl.TouchFingerEvent:
+			a.handleTouch(e)
 		case *sdl.ControllerButtonEvent:
 			a.handleGamepadButton(e)
 		case *sdl.ControllerAxisEvent:
@@ -940,38 +556,52 @@ func (a *App) handleKeyboard(e *sdl.KeyboardEvent) {
 	if e.Type != sdl.KEYDOWN || a.terminal.pty == nil {
 		return
 	}
+	// 输入法组字期间的按键（回车、退格等）由输入法处理
+	if a.ime.preedit != "" {
+		return
+	}
+	a.ime.suppress = false
+	// 子进程退出后回车重启
+	if a.childExited {
+		if e.Keysym.Sym == sdl.K_RETURN || e.Keysym.Sym == sdl.K_KP_ENTER {
+			a.restartTerminal()
+		}
+		return
+	}
 	key := e.Keysym.Sym
 	mod := e.Keysym.Mod
-	if mod&sdl.KMOD_CTRL != 0 {
-		if sequence, exists := a.keyMaps.ctrlKeys[key]; exists {
-			a.terminal.pty.WriteString(sequence)
+	// Ctrl+Shift+C/V 复制选区和粘贴，Shift+Insert 粘贴
+	if mod&sdl.KMOD_CTRL != 0 && mod&sdl.KMOD_SHIFT != 0 {
+		switch key {
+		case sdl.K_c:
+			a.copySelection()
 			return
-		}
-	} else if mod&sdl.KMOD_ALT != 0 {
-		if sequence, exists := a.keyMaps.altKeys[key]; exists {
-			a.terminal.pty.WriteString(sequence)
+		case sdl.K_v:
+			a.pasteClipboard()
 			return
 		}
-	} else if sequence, exists := a.keyMaps.functionKeys[key]; exists {
-		a.terminal.pty.WriteString(sequence)
+	}
+	if key == sdl.K_INSERT && mod&sdl.KMOD_SHIFT != 0 {
+		a.pasteClipboard()
 		return
-	} else {
-		shifted := mod&sdl.KMOD_SHIFT != 0
-		capsLock := mod&sdl.KMOD_CAPS != 0
-		var char string
-		var exists bool
-		if shifted {
-			char, exists = a.keyMaps.shiftKeys[key]
+	}
+	// Ctrl+Shift+F 打开或关闭历史搜索
+	if key == sdl.K_f && mod&sdl.KMOD_CTRL != 0 && mod&sdl.KMOD_SHIFT != 0 {
+		if a.search.active {
+			a.closeSearch()
 		} else {
-			char, exists = a.keyMaps.normalKeys[key]
-		}
-		// 处理大写锁定 (只影响字母)
-		if exists && !shifted && capsLock && key >= sdl.K_a && key <= sdl.K_z {
-			char = a.keyMaps.shiftKeys[key] // 获取大写字母
-		}
-		if exists {
-			a.terminal.pty.WriteString(char)
+			a.openSearch()
 		}
+		return
+	}
+	if a.search.active {
+		a.handleSearchKey(key, mod)
+		return
+	}
+	if ev, exists := a.keyMaps.keyEvent(key, mod); exists {
+		// 部分平台在 Alt 组合键之后仍会产生文字，已经按组合键发送过的不再重复发送
+		a.ime.suppress = ev.Key == vt.KeyNone
+		a.sendKey(ev)
 	}
 }
 func (a *App) handleGamepadAxis(e *sdl.ControllerAxisEvent) {
@@ -1015,6 +645,11 @@ func (a *App) handleGamepadAxis(e *sdl.ControllerAxisEvent) {
 
 func (a *App) handleGamepadButton(e *sdl.ControllerButtonEvent) {
 	if e.Type == uint32(sdl.CONTROLLERBUTTONDOWN) {
+		// 选择模式下除 Back/Start 外的按键用于选择
+		if a.selection.gamepadMode && e.Button != sdl.CONTROLLER_BUTTON_BACK && e.Button != sdl.CONTROLLER_BUTTON_START {
+			a.handleSelectionButton(sdl.GameControllerButton(e.Button))
+			return
+		}
 		switch e.Button {
 		case sdl.CONTROLLER_BUTTON_DPAD_UP:
 			a.DealWithMove(-1, 0)
@@ -1025,15 +660,41 @@ func (a *App) handleGamepadButton(e *sdl.ControllerButtonEvent) {
 		case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
 			a.DealWithMove(0, 1)
 		case sdl.CONTROLLER_BUTTON_LEFTSHOULDER:
-			a.DealwithInput(BTN_CLEAR)
+			// 搜索模式下肩键在匹配之间跳转
+			if a.search.active {
+				a.stepSearch(-1)
+			} else {
+				a.DealwithInput(BTN_CLEAR)
+			}
 		case sdl.CONTROLLER_BUTTON_RIGHTSHOULDER:
-			a.DealwithInput(BTN_ENTER)
+			if a.search.active {
+				a.stepSearch(1)
+			} else {
+				a.DealwithInput(BTN_ENTER)
+			}
+		case sdl.CONTROLLER_BUTTON_RIGHTSTICK:
+			// 按下右摇杆进入选择模式
+			a.toggleGamepadSelection()
+		case sdl.CONTROLLER_BUTTON_LEFTSTICK:
+			// 按下左摇杆打开或关闭历史搜索
+			if a.search.active {
+				a.closeSearch()
+			} else {
+				a.openSearch()
+			}
 		case sdl.CONTROLLER_BUTTON_X:
 			a.DealwithInput(BTN_DEL)
+		case sdl.CONTROLLER_BUTTON_Y:
+			a.toggleKeyboard()
 		case sdl.CONTROLLER_BUTTON_B:
 			a.DealwithInput("")
 		case sdl.CONTROLLER_BUTTON_A:
-			a.DealwithInput(BTN_SPACE)
+			// 子进程退出后 A 键重启
+			if a.childExited {
+				a.restartTerminal()
+			} else {
+				a.DealwithInput(BTN_SPACE)
+			}
 		// case sdl.CONTROLLER_BUTTON_DPAD_LEFT:
 		// 	a.DealwithInput(BTN_HIS_PRE)
 		// case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
@@ -1044,7 +705,7 @@ func (a *App) handleGamepadButton(e *sdl.ControllerButtonEvent) {
 			a.startPressed = true
 		}
 		if a.backPressed && a.startPressed {
-			a.running = false
+			a.running.Store(false)
 		}
 	} else if e.Type == sdl.CONTROLLERBUTTONUP {
 		switch e.Button {
@@ -1070,29 +731,40 @@ func (a *App) DealwithInput(key string) {
 	if key == "" {
 		key = a.keyBoards[a.selectedRow][a.selectedCol]
 	}
+	// 子进程退出后不再写入 pty，回车键重启
+	if a.childExited {
+		if key == BTN_ENTER {
+			a.restartTerminal()
+		}
+		return
+	}
+	if a.search.active {
+		a.handleSearchButton(key)
+		return
+	}
 	switch key {
 	case BTN_ENTER:
-		a.terminal.pty.WriteString("\n")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyEnter})
 	case BTN_SPACE:
-		a.terminal.pty.WriteString(" ")
+		a.sendInput(" ")
 	case BTN_DEL:
-		a.terminal.pty.WriteString("\b")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyBackspace})
 	case BTN_CTRLC:
-		a.terminal.pty.WriteString("\x03")
+		a.sendKey(vt.KeyEvent{Code: 'c', Text: "c", Mod: vt.ModCtrl})
 	case BTN_ESC:
-		a.terminal.pty.WriteString("\x1b")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyEscape})
 	case BTN_CLEAR:
-		a.terminal.pty.WriteString("clear\n")
+		a.sendKey(vt.KeyEvent{Code: 'l', Text: "l", Mod: vt.ModCtrl})
 	case BTN_HIS_PRE:
-		a.terminal.pty.WriteString("\x1b[A")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyUp})
 	case BTN_HIS_NXT:
-		a.terminal.pty.WriteString("\x1b[B")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyDown})
 	case BTN_CAPS:
 		a.DealWithCapsLock()
 	case BTN_TAB:
-		a.terminal.pty.WriteString("\t")
+		a.sendKey(vt.KeyEvent{Key: vt.KeyTab})
 	default:
-		a.terminal.pty.WriteString(key)
+		a.sendInput(key)
 	}
 }
 
@@ -1118,71 +790,126 @@ func (a *App) DealWithCapsLock() {
 func (a *App) renderTerminal() {
 	// 终端背景
 	terminalRect := sdl.Rect{X: 0, Y: 0, W: int32(a.Cfg.Window_Width), H: int32(a.Cfg.terminal_height)}
-	a.renderer.SetDrawColor(30, 30, 30, 255)
+	a.renderer.SetDrawColor(vt.DefaultBg.R, vt.DefaultBg.G, vt.DefaultBg.B, 255)
 	a.renderer.FillRect(&terminalRect)
 
+	// 先复制有变化的行，绘制期间不持有终端的锁
+	a.terminal.Snapshot(&a.screen)
+	s := &a.screen
+	// 终端内容画在目标纹理上，只重画有变化的行；不支持目标纹理时每帧重画所有行
+	texture := a.ensureTermTexture(int32(a.Cfg.Window_Width), int32(len(s.Rows)*a.Cfg.char_height))
+	originY := int32(0)
+	if texture != nil {
+		a.renderer.SetRenderTarget(texture)
+	} else {
+		originY = int32(a.Cfg.title_bar_height)
+	}
+	for y := range s.Rows {
+		if texture == nil || s.Dirty[y] {
+			a.renderRow(y, originY+int32(y*a.Cfg.char_height))
+		}
+		s.Dirty[y] = false
+	}
+	if texture != nil {
+		a.renderer.SetRenderTarget(nil)
+		_, _, w, h, _ := texture.Query()
+		a.renderer.Copy(texture, nil, &sdl.Rect{X: 0, Y: int32(a.Cfg.title_bar_height), W: w, H: h})
+	}
+	a.setFontStyle(0)
+
 	// 终端边框
 	a.renderer.SetDrawColor(100, 100, 100, 255)
 	a.renderer.DrawRect(&terminalRect)
+}
 
-	a.terminal.mutex.RLock()
-	cursorX := a.terminal.cursorX
-	cursorY := a.terminal.cursorY
-
-	// 光标闪烁效果
-	if time.Since(a.terminal.lastBlink) > 500*time.Millisecond {
-		a.terminal.cursorVisible = !a.terminal.cursorVisible
-		a.terminal.lastBlink = time.Now()
-	}
-
-	// 渲染终端内容
-	for y := 0; y < a.terminal.screenHeight; y++ {
-		lineY := int32(y * a.Cfg.char_height)
-		displayX := 0 // 实际显示位置
-
-		for x := 0; x < a.terminal.screenWidth; x++ {
-			cell := a.terminal.screenBuffer[y][x]
+// renderRow 绘制快照中的一行，lineY 为该行顶部的坐标
+func (a *App) renderRow(y int, lineY int32) {
+	s := &a.screen
+	rowH := int32(a.Cfg.char_height)
+	a.renderer.SetDrawColor(vt.DefaultBg.R, vt.DefaultBg.G, vt.DefaultBg.B, 255)
+	a.renderer.FillRect(&sdl.Rect{X: 0, Y: lineY, W: int32(a.Cfg.Window_Width), H: rowH})
+
+	displayX := 0 // 实际显示位置
+	for x, cell := range s.Rows[y] {
+		// 跳过占位符（宽字符的第二部分）
+		if cell.Width == 0 {
+			continue
+		}
 
-			// 跳过占位符（宽字符的第二部分）
-			if cell.width == 0 {
-				continue
+		charX := int32(displayX * a.Cfg.char_width)
+		cellW := int32(cell.Width * a.Cfg.char_width)
+		// 闪烁文字与光标使用同一闪烁节奏
+		fg, bg := cell.Style.Colors(a.blinkOn)
+		// 搜索匹配高亮，行号换算为包含历史的绝对行号
+		line := s.FirstLine + s.HistoryLines - s.ViewOffset + y
+		if a.search.active {
+			if matched, current := a.search.highlight(line, x); matched {
+				fg, bg = vt.RGB{}, searchMatchBg
+				if current {
+					bg = searchCurrentBg
+				}
 			}
+		}
+		// 选中的文字反色显示
+		if a.selection.selected(line, x) {
+			fg, bg = bg, fg
+		}
 
-			charX := int32(displayX * a.Cfg.char_width)
+		// 渲染背景色
+		if bg != vt.DefaultBg {
+			a.renderer.SetDrawColor(bg.R, bg.G, bg.B, 255)
+			a.renderer.FillRect(&sdl.Rect{X: charX, Y: lineY, W: cellW, H: rowH})
+		}
 
+		if fg != bg {
 			// 渲染字符（现在支持UTF-8）
-			if cell.char != " " {
-				a.renderText(cell.char, charX, lineY, 220, 220, 220) // 浅灰色文本
+			if cell.Char != " " {
+				a.setFontStyle(cell.Style.Attr)
+				a.renderText(cell.Char, charX, lineY+int32(a.Cfg.glyph_offset_y), fg.R, fg.G, fg.B)
 			}
-
-			// 渲染光标（闪烁效果）- 调整光标大小适应20号字体
-			if y == cursorY && x == cursorX && a.terminal.cursorVisible {
-				cursorRect := sdl.Rect{
-					X: charX,
-					Y: lineY,
-					W: 3, // 增加光标宽度适应更大字体
-					H: int32(a.Cfg.char_height),
-				}
-				a.renderer.SetDrawColor(0, 255, 0, 255) // 绿色光标
-				a.renderer.FillRect(&cursorRect)
+			// 下划线和删除线
+			a.renderer.SetDrawColor(fg.R, fg.G, fg.B, 255)
+			if cell.Style.Attr&vt.AttrUnderline != 0 {
+				underlineY := lineY + a.Cfg.underlineOffset()
+				a.renderer.DrawLine(charX, underlineY, charX+cellW-1, underlineY)
 			}
+			if cell.Style.Attr&vt.AttrStrike != 0 {
+				strikeY := lineY + a.Cfg.strikeOffset()
+				a.renderer.DrawLine(charX, strikeY, charX+cellW-1, strikeY)
+			}
+		}
 
-			displayX += cell.width // 根据字符宽度更新显示位置
+		// 渲染光标（闪烁效果）- 调整光标大小适应20号字体
+		if y == s.CursorY && x == s.CursorX && a.blinkOn {
+			cursorRect := sdl.Rect{
+				X: charX,
+				Y: lineY,
+				W: 3, // 增加光标宽度适应更大字体
+				H: rowH,
+			}
+			a.renderer.SetDrawColor(0, 255, 0, 255) // 绿色光标
+			a.renderer.FillRect(&cursorRect)
 		}
+
+		displayX += cell.Width // 根据字符宽度更新显示位置
 	}
-	a.terminal.mutex.RUnlock()
 }
 
-func (a *App) renderKeyboard() {
-	keyboardY := a.Cfg.terminal_height
-	// 键盘背景
-	a.renderer.SetDrawColor(45, 45, 45, 255)
-	a.renderer.FillRect(&sdl.Rect{X: 0, Y: int32(keyboardY), W: int32(a.Cfg.Window_Width), H: int32(a.Cfg.keyboard_height)})
-
-	// 区域分隔线
-	a.renderer.SetDrawColor(80, 80, 80, 255)
-	a.renderer.DrawLine(0, int32(keyboardY), int32(a.Cfg.Window_Width), int32(keyboardY))
+// setFontStyle 根据单元格属性选择粗体/斜体，字体的 SetStyle 只在字形缓存未命中时调用
+func (a *App) setFontStyle(attr vt.Attr) {
+	style := ttf.STYLE_NORMAL
+	if attr&vt.AttrBold != 0 {
+		style |= ttf.STYLE_BOLD
+	}
+	if attr&vt.AttrItalic != 0 {
+		style |= ttf.STYLE_ITALIC
+	}
+	a.fontStyle = style
+}
 
+// keyRect 返回虚拟键盘第 row 行第 col 个按键的位置，绘制和点击共用
+func (a *App) keyRect(row, col int) sdl.Rect {
+	keyboardY := a.Cfg.terminal_height
 	totalRows := len(a.keyBoards)
 	margin := 3 // 适当减小间距适应较大字体
 
@@ -1198,21 +925,55 @@ func (a *App) renderKeyboard() {
 	// 垂直居中起始位置
 	startY := keyboardY + 8 // 固定边距
 
+	// 计算该行的起始X位置（水平居中）
+	keys := a.keyBoards[row]
+	totalRowWidth := len(keys)*keyWidth + (len(keys)-1)*margin
+	rowStartX := (a.Cfg.Window_Width - totalRowWidth) / 2
+
+	rowY := startY + row*(keyHeight+margin)
+	keyX := rowStartX + col*(keyWidth+margin)
+	return sdl.Rect{X: int32(keyX), Y: int32(rowY), W: int32(keyWidth), H: int32(keyHeight)}
+}
+
+// keyAt 返回窗口坐标处的虚拟键盘按键，键盘隐藏或不在按键上时返回 false
+func (a *App) keyAt(x, y int32) (row, col int, ok bool) {
+	if !a.keyboardVisible {
+		return 0, 0, false
+	}
+	p := sdl.Point{X: x, Y: y}
 	for row, keys := range a.keyBoards {
-		// 计算该行的起始X位置（水平居中）
-		totalRowWidth := len(keys)*keyWidth + (len(keys)-1)*margin
-		rowStartX := (a.Cfg.Window_Width - totalRowWidth) / 2
+		for col := range keys {
+			rect := a.keyRect(row, col)
+			if p.InRect(&rect) {
+				return row, col, true
+			}
+		}
+	}
+	return 0, 0, false
+}
 
-		rowY := startY + row*(keyHeight+margin)
+func (a *App) renderKeyboard() {
+	if !a.keyboardVisible {
+		return
+	}
+	keyboardY := a.Cfg.terminal_height
+	// 键盘背景
+	a.renderer.SetDrawColor(45, 45, 45, 255)
+	a.renderer.FillRect(&sdl.Rect{X: 0, Y: int32(keyboardY), W: int32(a.Cfg.Window_Width), H: int32(a.Cfg.keyboard_height)})
 
-		for col, key := range keys {
-			keyX := rowStartX + col*(keyWidth+margin)
+	// 区域分隔线
+	a.renderer.SetDrawColor(80, 80, 80, 255)
+	a.renderer.DrawLine(0, int32(keyboardY), int32(a.Cfg.Window_Width), int32(keyboardY))
 
+	for row, keys := range a.keyBoards {
+		for col, key := range keys {
 			// 按键背景
-			keyRect := sdl.Rect{X: int32(keyX), Y: int32(rowY), W: int32(keyWidth), H: int32(keyHeight)}
+			keyRect := a.keyRect(row, col)
 
 			// 选中状态
-			if row == a.selectedRow && col == a.selectedCol {
+			if a.touch.keyDown && row == a.touch.keyRow && col == a.touch.keyCol {
+				a.renderer.SetDrawColor(135, 206, 250, 255) // 浅蓝色触摸按下状态
+			} else if row == a.selectedRow && col == a.selectedCol {
 				a.renderer.SetDrawColor(70, 130, 180, 255) // 蓝色选中状态
 			} else if key == BTN_CAPS && a.capsLock {
 				a.renderer.SetDrawColor(220, 20, 60, 255) // 红色大写锁定
@@ -1232,28 +993,15 @@ func (a *App) renderKeyboard() {
 			}
 
 			// 更好的文字居中计算，适应20号字体
-			textX := int32(keyX + keyWidth/2 - len(key)*4) // 根据20号字体调整文字位置
-			textY := int32(rowY + keyHeight/2 - 10)        // 调整垂直居中位置
+			textX := keyRect.X + keyRect.W/2 - int32(len(key)*4) // 根据20号字体调整文字位置
+			textY := keyRect.Y + keyRect.H/2 - 10                // 调整垂直居中位置
 			a.renderText(key, textX, textY, textColor, textColor, textColor)
 		}
 	}
 }
 
 func (a *App) renderText(text string, x, y int32, r, g, b uint8) {
-	if text == "" {
-		return
-	}
-	surface, err := a.font.RenderUTF8Solid(text, sdl.Color{R: r, G: g, B: b, A: 255})
-	if err != nil {
-		return
-	}
-	defer surface.Free()
-	texture, err := a.renderer.CreateTextureFromSurface(surface)
-	if err != nil {
-		return
-	}
-	defer texture.Destroy()
-	a.renderer.Copy(texture, nil, &sdl.Rect{X: x, Y: y, W: surface.W, H: surface.H})
+	a.glyphs.drawString(text, a.fontStyle, x, y, r, g, b)
 }
 
 func (a *App) Close() {
@@ -1263,6 +1011,12 @@ func (a *App) Close() {
 	if a.terminal != nil {
 		a.terminal.Close()
 	}
+	if a.termTexture != nil {
+		a.termTexture.Destroy()
+	}
+	if a.glyphs != nil {
+		a.glyphs.reset()
+	}
 	if a.font != nil {
 		a.font.Close()
 	}
@@ -1289,18 +1043,19 @@ func main() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigs
-		app.running = false
+		app.running.Store(false)
+		// 唤醒可能正在等待事件的主循环
+		sdl.PushEvent(&sdl.QuitEvent{Type: sdl.QUIT})
 	}()
-	// step3. start msg cycle
-	for app.running {
-		app.handleInput()
-		app.renderer.SetDrawColor(0, 0, 0, 255)
-		app.renderer.Clear()
-
-		app.renderTerminal()
-		app.renderKeyboard()
-
-		app.renderer.Present()
-		sdl.Delay(16)
+	// step3. start msg cycle, only render when something changed
+	for app.running.Load() {
+		app.handleInput(app.waitTimeout())
+		app.checkChildExit()
+		app.updateTitle()
+		app.tickBlink()
+		app.tickKeyRepeat()
+		if app.needsRender() && time.Since(app.lastFrame) >= app.frameInterval() {
+			app.render()
+		}
 	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// applyFontMetrics 根据字体的实际度量计算单元格尺寸
+// 宽度取 "M" 的步进宽度，高度取 LineSkip（行距）加上 line_spacing；配置了 cell_width/cell_height 时优先使用
+func (c *Config) applyFontMetrics(font *ttf.Font) error {
+	width := c.CellWidth
+	if width <= 0 {
+		metrics, err := font.GlyphMetrics('M')
+		if err == nil && metrics.Advance > 0 {
+			width = metrics.Advance
+		} else if w, _, err := font.SizeUTF8("M"); err == nil {
+			width = w
+		}
+	}
+	if width <= 0 {
+		return fmt.Errorf("无法从字体获取字符宽度，请在配置中设置 cell_width")
+	}
+
+	fontHeight := font.Height()
+	height := c.CellHeight
+	if height <= 0 {
+		height = max(font.LineSkip(), fontHeight) + c.LineSpacing
+	}
+	if height <= 0 {
+		return fmt.Errorf("无法从字体获取字符高度，请在配置中设置 cell_height")
+	}
+
+	c.char_width = width
+	c.char_height = height
+	c.char_ascent = font.Ascent()
+	// 单元格比字体高时字形垂直居中，多出的行距平均分到上下
+	c.glyph_offset_y = (height - fontHeight) / 2
+	return nil
+}
+
+// underlineOffset 下划线相对单元格顶部的位置：基线下方一个像素，且不超出单元格
+func (c *Config) underlineOffset() int32 {
+	return int32(min(c.glyph_offset_y+c.char_ascent+1, c.char_height-1))
+}
+
+// strikeOffset 删除线相对单元格顶部的位置：大约在小写字母的中部
+func (c *Config) strikeOffset() int32 {
+	return int32(max(0, c.glyph_offset_y+c.char_ascent*2/3))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"main/vt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// mouseReport 向程序报告鼠标事件的状态
+// 程序开启鼠标跟踪后，终端区域内的鼠标事件编码后发送给程序；按住 Shift 时仍可以选择文本
+type mouseReport struct {
+	pressed  vt.MouseButton // 按住的按键，没有时为 MouseNoButton
+	reported bool           // 按下已报告给程序，松开和拖动也应报告
+	lastCell vt.Point       // 上次报告的位置，移动时只在换到新的单元格时报告（Line 为屏幕行）
+}
+
+// mouseButtons SDL 鼠标按键与终端鼠标按键的对应
+var mouseButtons = map[uint8]vt.MouseButton{
+	sdl.BUTTON_LEFT:   vt.MouseLeft,
+	sdl.BUTTON_MIDDLE: vt.MouseMiddle,
+	sdl.BUTTON_RIGHT:  vt.MouseRight,
+}
+
+// mouseMod 当前按住的修饰键
+func mouseMod() vt.Mod {
+	mod := sdl.GetModState()
+	var m vt.Mod
+	if mod&sdl.KMOD_SHIFT != 0 {
+		m |= vt.ModShift
+	}
+	if mod&sdl.KMOD_ALT != 0 {
+		m |= vt.ModAlt
+	}
+	if mod&sdl.KMOD_CTRL != 0 {
+		m |= vt.ModCtrl
+	}
+	return m
+}
+
+// mouseTracking 程序是否请求了鼠标事件，按住 Shift 时鼠标由终端自己处理
+func (a *App) mouseTracking() bool {
+	return a.terminal.Modes().MouseTracking != vt.MouseTrackingNone && sdl.GetModState()&sdl.KMOD_SHIFT == 0
+}
+
+// inTerminal 窗口坐标是否在终端区域内
+func (a *App) inTerminal(y int32) bool {
+	return int(y) >= a.Cfg.title_bar_height && int(y) < a.Cfg.terminal_height
+}
+
+// screenCell 将窗口坐标换算为屏幕上的单元格，超出终端区域时限制在边界上
+func (a *App) screenCell(x, y int32) vt.Point {
+	cols, rows := a.terminal.Size()
+	col := max(0, min(cols-1, int(x)/a.Cfg.char_width))
+	row := max(0, min(rows-1, (int(y)-a.Cfg.title_bar_height)/a.Cfg.char_height))
+	return vt.Point{Line: row, Col: col}
+}
+
+// reportMouse 编码鼠标事件并发送给程序
+func (a *App) reportMouse(action vt.MouseAction, button vt.MouseButton, x, y int32) {
+	cell := a.screenCell(x, y)
+	a.mouse.lastCell = cell
+	ev := vt.MouseEvent{Action: action, Button: button, X: cell.Col, Y: cell.Line, Mod: mouseMod()}
+	if sequence := vt.EncodeMouse(ev, a.terminal.Modes()); sequence != "" {
+		a.sendInput(sequence)
+	}
+}
+
+// reportMouseButton 程序请求鼠标事件时报告按下和松开，返回 false 时由终端自己处理
+func (a *App) reportMouseButton(e *sdl.MouseButtonEvent) bool {
+	button, exists := mouseButtons[e.Button]
+	if !exists {
+		return false
+	}
+	if e.Type == sdl.MOUSEBUTTONDOWN {
+		if !a.inTerminal(e.Y) || !a.mouseTracking() {
+			return false
+		}
+		a.clearSelection()
+		a.mouse.pressed, a.mouse.reported = button, true
+		a.reportMouse(vt.MousePress, button, e.X, e.Y)
+		return true
+	}
+	// 松开的按键只在按下已报告时报告，拖出终端区域后松开也要报告
+	if !a.mouse.reported || button != a.mouse.pressed {
+		return false
+	}
+	a.mouse.pressed, a.mouse.reported = vt.MouseNoButton, false
+	a.reportMouse(vt.MouseRelease, button, e.X, e.Y)
+	return true
+}
+
+// reportMouseMotion 程序请求移动事件时报告移动，返回 false 时由终端自己处理
+func (a *App) reportMouseMotion(e *sdl.MouseMotionEvent) bool {
+	if !a.mouse.reported && (!a.inTerminal(e.Y) || !a.mouseTracking()) {
+		return false
+	}
+	if cell := a.screenCell(e.X, e.Y); cell != a.mouse.lastCell {
+		a.reportMouse(vt.MouseMotion, a.mouse.pressed, e.X, e.Y)
+	}
+	return true
+}
+
+// reportMouseWheel 程序请求鼠标事件时将滚轮的每一格报告为一次按下，返回 false 时滚动历史
+func (a *App) reportMouseWheel(e *sdl.MouseWheelEvent) bool {
+	x, y, _ := sdl.GetMouseState()
+	if !a.inTerminal(y) || !a.mouseTracking() {
+		return false
+	}
+	report := func(n int32, positive, negative vt.MouseButton) {
+		button := positive
+		if n < 0 {
+			button, n = negative, -n
+		}
+		for range n {
+			a.reportMouse(vt.MousePress, button, x, y)
+		}
+	}
+	report(e.Y, vt.MouseWheelUp, vt.MouseWheelDown)
+	report(e.X, vt.MouseWheelRight, vt.MouseWheelLeft)
+	return true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// 子进程退出后的行为
+const (
+	onExitWait    = "wait"    // 显示退出提示，等待用户重启或退出
+	onExitClose   = "close"   // 直接关闭程序
+	onExitRestart = "restart" // 自动重启子进程
+)
+
+// minRestartInterval 自动重启的最小间隔，子进程启动后立即退出时不再自动重启，避免循环
+const minRestartInterval = time.Second
+
+// waitChild 等待子进程退出并记录退出码
+func (t *Terminal) waitChild() {
+	err := t.cmd.Wait()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+		// 被信号终止时按 shell 惯例记为 128+信号值
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+	} else if err != nil {
+		code = -1
+	}
+
+	t.mutex.Lock()
+	t.exited = true
+	t.exitCode = code
+	t.mutex.Unlock()
+	close(t.done)
+}
+
+// ExitStatus 返回子进程的退出码以及是否已经退出
+func (t *Terminal) ExitStatus() (code int, exited bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.exitCode, t.exited
+}
+
+// checkChildExit 检查子进程是否退出，并按配置关闭、重启或显示提示
+func (a *App) checkChildExit() {
+	if a.childExited {
+		return
+	}
+	if _, exited := a.terminal.ExitStatus(); !exited {
+		return
+	}
+	a.redraw = true
+	// 子进程退出后不再重复按住的虚拟键盘按键
+	a.touch.keyDown = false
+	switch a.Cfg.OnExit {
+	case onExitClose:
+		a.running.Store(false)
+	case onExitRestart:
+		if time.Since(a.startTime) >= minRestartInterval {
+			a.restartTerminal()
+			return
+		}
+		a.childExited = true
+	default:
+		a.childExited = true
+	}
+}
+
+// restartTerminal 关闭当前终端并以相同尺寸重新启动子进程
+func (a *App) restartTerminal() {
+	old := a.terminal
+	old.Close()
+	// 按 ENTER 重启时，按住的按键不应在新的子进程中继续重复
+	a.touch.keyDown = false
+	cols, rows := old.Size()
+	terminal, err := NewTerminal(a.Cfg, cols, rows)
+	if err != nil {
+		fmt.Printf("重启终端失败: %v\n", err)
+		a.childExited = true
+		return
+	}
+	a.terminal = terminal
+	a.childExited = false
+	a.startTime = time.Now()
+	a.redraw = true
+	go a.forwardWakeups(terminal)
+}
+
+// renderExitBanner 在终端区域底部显示子进程退出提示
+func (a *App) renderExitBanner() {
+	if !a.childExited {
+		return
+	}
+	code, _ := a.terminal.ExitStatus()
+	bannerH := int32(2*a.Cfg.char_height + 8)
+	bannerRect := sdl.Rect{
+		X: 0,
+		Y: int32(a.Cfg.terminal_height) - bannerH,
+		W: int32(a.Cfg.Window_Width),
+		H: bannerH,
+	}
+	a.renderer.SetDrawColor(120, 30, 30, 255)
+	a.renderer.FillRect(&bannerRect)
+	a.renderText(fmt.Sprintf("[process exited with code %d]", code), 4, bannerRect.Y+4, 255, 255, 255)
+	a.renderText("press A to restart / Start+Back to quit", 4, bannerRect.Y+4+int32(a.Cfg.char_height), 255, 255, 255)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/creack/pty"
+)
+
+// Resize 调整终端尺寸，并通过 pty.Setsize 通知子进程（内核会发送 SIGWINCH）
+func (t *Terminal) Resize(cols, rows int) {
+	cols, rows = max(1, cols), max(1, rows)
+	t.Emulator.Resize(cols, rows)
+	if err := pty.Setsize(t.pty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}); err != nil {
+		fmt.Printf("设置窗口大小失败: %v\n", err)
+	}
+}
+
+// updateLayout 根据窗口尺寸和虚拟键盘是否显示计算终端和键盘区域的高度
+func (c *Config) updateLayout(keyboardVisible bool) {
+	if keyboardVisible {
+		c.terminal_height = int(math.Round(float64(c.Window_Height) * c.TerminalRatio))
+		c.keyboard_height = int(math.Round(float64(c.Window_Height) * c.KeyboardRatio))
+	} else {
+		c.terminal_height = c.Window_Height
+		c.keyboard_height = 0
+	}
+}
+
+// terminalSize 根据当前布局计算终端的列数和行数
+func (c *Config) terminalSize() (cols, rows int) {
+	cols = max(1, c.Window_Width/c.char_width)
+	rows = max(1, (c.terminal_height-c.title_bar_height)/c.char_height)
+	return cols, rows
+}
+
+// relayout 重新计算布局并调整终端尺寸，在窗口尺寸变化或键盘显示/隐藏时调用
+func (a *App) relayout() {
+	a.Cfg.updateLayout(a.keyboardVisible)
+	a.terminal.Resize(a.Cfg.terminalSize())
+}
+
+// toggleKeyboard 显示或隐藏虚拟键盘，隐藏时终端占满整个窗口
+func (a *App) toggleKeyboard() {
+	a.keyboardVisible = !a.keyboardVisible
+	a.relayout()
+}
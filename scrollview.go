@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// sendInput 将按键产生的输入写入 pty，配置了 scroll_on_input 时先回到最新的内容
+func (a *App) sendInput(s string) {
+	if a.Cfg.ScrollOnInput {
+		a.terminal.ScrollToBottom()
+	}
+	a.terminal.pty.WriteString(s)
+}
+
+// renderScrollIndicator 向上滚动查看历史时在终端右上角显示已滚动的行数
+func (a *App) renderScrollIndicator() {
+	s := &a.screen
+	if s.ViewOffset == 0 {
+		return
+	}
+	text := fmt.Sprintf("[scrolled %d/%d lines]", s.ViewOffset, s.HistoryLines)
+	w := int32(len(text)*a.Cfg.char_width + 8)
+	rect := sdl.Rect{
+		X: int32(a.Cfg.Window_Width) - w - 4,
+		Y: int32(a.Cfg.title_bar_height) + 4,
+		W: w,
+		H: int32(a.Cfg.char_height + 4),
+	}
+	a.renderer.SetDrawColor(60, 60, 120, 255)
+	a.renderer.FillRect(&rect)
+	a.renderText(text, rect.X+4, rect.Y+2, 255, 255, 255)
+}
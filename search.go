@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"main/vt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var (
+	searchMatchBg   = vt.RGB{R: 120, G: 110, B: 30} // 匹配的背景色
+	searchCurrentBg = vt.RGB{R: 230, G: 150, B: 30} // 当前匹配的背景色
+)
+
+// searchState 历史搜索的状态
+// 搜索从最新的内容开始向上查找：“下一个”是更早（更靠上）的匹配，“上一个”是更新的匹配
+type searchState struct {
+	active        bool
+	query         string
+	regex         bool // 按正则表达式匹配，否则按普通文本匹配
+	caseSensitive bool
+	err           error      // 正则表达式无效时的错误
+	matches       []vt.Match // 按行和列排序
+	current       int        // 当前匹配在 matches 中的下标，没有匹配时为 -1
+}
+
+// compile 根据查询文本和选项生成正则表达式
+func (s *searchState) compile() (*regexp.Regexp, error) {
+	pattern := s.query
+	if !s.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !s.caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// highlight 返回第 line 行第 x 列是否在匹配中，以及是否为当前匹配
+func (s *searchState) highlight(line, x int) (matched, current bool) {
+	i := sort.Search(len(s.matches), func(i int) bool {
+		m := s.matches[i]
+		return m.Line > line || m.Line == line && m.End > x
+	})
+	if i < len(s.matches) && s.matches[i].Line == line && s.matches[i].Start <= x {
+		return true, i == s.current
+	}
+	return false, false
+}
+
+// openSearch 进入搜索模式
+func (a *App) openSearch() {
+	a.search = searchState{active: true, current: -1}
+	a.screen.MarkAllDirty()
+}
+
+// closeSearch 退出搜索模式并清除高亮，视图停留在当前位置
+func (a *App) closeSearch() {
+	a.search = searchState{current: -1}
+	a.screen.MarkAllDirty()
+}
+
+// updateSearch 查询或选项变化后重新搜索，并跳到最新的匹配
+func (a *App) updateSearch() {
+	s := &a.search
+	s.matches, s.current, s.err = nil, -1, nil
+	if s.query != "" {
+		re, err := s.compile()
+		if err != nil {
+			s.err = err
+		} else {
+			s.matches = a.terminal.Search(re)
+			s.current = len(s.matches) - 1
+		}
+	}
+	a.jumpToMatch()
+}
+
+// stepSearch 跳到更早（delta 为 1）或更新（delta 为 -1）的匹配，到头后从另一端继续
+// 每次跳转前重新搜索，使结果包含新的输出
+func (a *App) stepSearch(delta int) {
+	s := &a.search
+	if s.query == "" || s.err != nil {
+		return
+	}
+	var old vt.Match
+	if s.current >= 0 {
+		old = s.matches[s.current]
+	}
+	re, _ := s.compile()
+	s.matches = a.terminal.Search(re)
+	if len(s.matches) == 0 {
+		s.current = -1
+		a.jumpToMatch()
+		return
+	}
+	// 在新结果中找到原来的匹配位置
+	i := sort.Search(len(s.matches), func(i int) bool {
+		m := s.matches[i]
+		return m.Line > old.Line || m.Line == old.Line && m.Start >= old.Start
+	})
+	if delta > 0 {
+		i--
+	} else if i < len(s.matches) && s.matches[i] == old {
+		i++
+	}
+	s.current = (i + len(s.matches)) % len(s.matches)
+	a.jumpToMatch()
+}
+
+// jumpToMatch 滚动到当前匹配，没有匹配时回到底部
+func (a *App) jumpToMatch() {
+	if a.search.current >= 0 {
+		a.terminal.ScrollToLine(a.search.matches[a.search.current].Line)
+	} else {
+		a.terminal.ScrollToBottom()
+	}
+	a.screen.MarkAllDirty()
+}
+
+// editSearch 在查询末尾追加文本，text 为空时删除最后一个字符
+func (a *App) editSearch(text string) {
+	s := &a.search
+	if text == "" {
+		runes := []rune(s.query)
+		if len(runes) == 0 {
+			return
+		}
+		s.query = string(runes[:len(runes)-1])
+	} else {
+		s.query += text
+	}
+	a.updateSearch()
+}
+
+// handleSearchKey 搜索模式下的物理键盘输入：
+// 输入文字（由 handleTextInput 送来，没有开启文字输入时查表）编辑查询，回车或 F3 查找下一个（加 Shift 为上一个），Alt+C 切换大小写敏感，Alt+R 切换正则，Esc 退出
+func (a *App) handleSearchKey(key sdl.Keycode, mod uint16) {
+	shifted := mod&sdl.KMOD_SHIFT != 0
+	switch {
+	case key == sdl.K_ESCAPE:
+		a.closeSearch()
+	case key == sdl.K_RETURN || key == sdl.K_KP_ENTER || key == sdl.K_F3:
+		if shifted {
+			a.stepSearch(-1)
+		} else {
+			a.stepSearch(1)
+		}
+	case key == sdl.K_BACKSPACE:
+		a.editSearch("")
+	case mod&sdl.KMOD_ALT != 0 && key == sdl.K_c:
+		a.search.caseSensitive = !a.search.caseSensitive
+		a.ime.suppress = true
+		a.updateSearch()
+	case mod&sdl.KMOD_ALT != 0 && key == sdl.K_r:
+		a.search.regex = !a.search.regex
+		a.ime.suppress = true
+		a.updateSearch()
+	case mod&(sdl.KMOD_CTRL|sdl.KMOD_ALT) != 0 || sdl.IsTextInputActive():
+	default:
+		char, exists := a.keyMaps.normalKeys[key]
+		if shifted || (mod&sdl.KMOD_CAPS != 0 && key >= sdl.K_a && key <= sdl.K_z) {
+			char, exists = a.keyMaps.shiftKeys[key]
+		}
+		if exists {
+			a.editSearch(char)
+		}
+	}
+}
+
+// handleSearchButton 搜索模式下虚拟键盘的按键：编辑查询，回车或 NXT 查找下一个，PRE 查找上一个，
+// Tab 切换大小写敏感，CLEAR 切换正则，Esc/^C 退出
+func (a *App) handleSearchButton(key string) {
+	switch key {
+	case BTN_ENTER, BTN_HIS_NXT:
+		a.stepSearch(1)
+	case BTN_HIS_PRE:
+		a.stepSearch(-1)
+	case BTN_TAB:
+		a.search.caseSensitive = !a.search.caseSensitive
+		a.updateSearch()
+	case BTN_CLEAR:
+		a.search.regex = !a.search.regex
+		a.updateSearch()
+	case BTN_DEL:
+		a.editSearch("")
+	case BTN_SPACE:
+		a.editSearch(" ")
+	case BTN_ESC, BTN_CTRLC:
+		a.closeSearch()
+	case BTN_CAPS:
+		a.DealWithCapsLock()
+	default:
+		a.editSearch(key)
+	}
+}
+
+// renderSearchBar 在终端区域底部显示查询、选项和匹配数
+func (a *App) renderSearchBar() {
+	s := &a.search
+	if !s.active {
+		return
+	}
+	barH := int32(a.Cfg.char_height + 4)
+	bar := sdl.Rect{X: 0, Y: int32(a.Cfg.terminal_height) - barH, W: int32(a.Cfg.Window_Width), H: barH}
+	a.renderer.SetDrawColor(40, 40, 90, 255)
+	a.renderer.FillRect(&bar)
+
+	// 选项的状态及其在虚拟键盘上的切换键，如 Aa=on(⇥)
+	option := func(name, key string, on bool) string {
+		state := "off"
+		if on {
+			state = "on"
+		}
+		return " " + name + "=" + state + "(" + key + ")"
+	}
+	flags := option("Aa", BTN_TAB, s.caseSensitive) + option(".*", BTN_CLEAR, s.regex)
+	var status string
+	switch {
+	case s.err != nil:
+		status = "invalid regex"
+	case s.query == "":
+		status = ""
+	case len(s.matches) == 0:
+		status = "no matches"
+	default:
+		status = fmt.Sprintf("%d/%d", len(s.matches)-s.current, len(s.matches))
+	}
+	a.renderText("search: "+s.query+"_", 4, bar.Y+2, 255, 255, 255)
+	if right := status + flags; right != "" {
+		x := int32(a.Cfg.Window_Width - (vt.StringWidth(right)+1)*a.Cfg.char_width)
+		a.renderText(right, x, bar.Y+2, 200, 200, 120)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+
+	"main/vt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// 选择的单位：单击拖动按单元格，双击按单词，三击按整行
+const (
+	selectCells = iota
+	selectWords
+	selectLines
+)
+
+// selection 文本选择的状态，位置使用包含历史的绝对行号，滚动视图时选区不变
+type selection struct {
+	active   bool // 有选区（或正在拖动）
+	dragging bool // 鼠标左键按下，正在拖动
+	unit     int
+	anchor   vt.Point // 开始选择的位置
+	head     vt.Point // 当前的另一端
+	from, to vt.Point // 按选择单位扩展后的选区（包含两端），绘制时使用
+	// 手柄选择模式：方向键移动选择光标，A 键设置起点/复制
+	gamepadMode bool
+	marked      bool // 手柄模式下是否已经设置了起点
+}
+
+// selectionBounds 返回按选择单位扩展后的选区起止位置（包含两端）
+func (a *App) selectionBounds() (vt.Point, vt.Point) {
+	sel := &a.selection
+	from, to := sel.anchor, sel.head
+	if to.Before(from) {
+		from, to = to, from
+	}
+	switch sel.unit {
+	case selectWords:
+		from, _ = a.terminal.WordAt(from)
+		_, to = a.terminal.WordAt(to)
+	case selectLines:
+		cols, _ := a.terminal.Size()
+		from.Col, to.Col = 0, cols-1
+	}
+	return from, to
+}
+
+// selected 第 line 行第 x 列是否在选区内
+func (sel *selection) selected(line, x int) bool {
+	p := vt.Point{Line: line, Col: x}
+	return sel.active && !p.Before(sel.from) && !sel.to.Before(p)
+}
+
+// viewTop 返回视图第一行的绝对行号
+func (a *App) viewTop() int {
+	_, rows := a.terminal.Size()
+	return a.terminal.Lines() - rows - a.terminal.ViewOffset()
+}
+
+// cellAt 将窗口坐标换算为绝对行号和列，超出终端区域时限制在边界上
+func (a *App) cellAt(x, y int32) vt.Point {
+	p := a.screenCell(x, y)
+	p.Line += a.viewTop()
+	return p
+}
+
+// setSelection 更新选区并重画
+func (a *App) setSelection(anchor, head vt.Point, unit int) {
+	a.selection.active = true
+	a.selection.anchor, a.selection.head, a.selection.unit = anchor, head, unit
+	a.selection.from, a.selection.to = a.selectionBounds()
+	a.screen.MarkAllDirty()
+}
+
+// clearSelection 取消选区
+func (a *App) clearSelection() {
+	if a.selection.active || a.selection.gamepadMode {
+		a.selection = selection{}
+		a.screen.MarkAllDirty()
+	}
+}
+
+// copySelection 将选区的文本复制到剪贴板
+func (a *App) copySelection() {
+	if !a.selection.active {
+		return
+	}
+	text := a.terminal.Text(a.selection.from, a.selection.to)
+	if text == "" {
+		return
+	}
+	if err := sdl.SetClipboardText(text); err != nil {
+		fmt.Printf("复制到剪贴板失败: %v\n", err)
+	}
+}
+
+// pasteClipboard 将剪贴板的文本粘贴到终端
+func (a *App) pasteClipboard() {
+	text, err := sdl.GetClipboardText()
+	if err != nil || text == "" {
+		return
+	}
+	a.paste(text)
+}
+
+// paste 粘贴文本，程序开启了括号粘贴模式时包围粘贴内容，多行文本不会被逐行执行
+// 所有粘贴的入口都应经过这里
+func (a *App) paste(text string) {
+	a.sendInput(vt.EncodePaste(text, a.terminal.Modes().BracketedPaste))
+}
+
+// handleMouseButton 程序请求鼠标事件时报告给程序，否则左键拖动选择（双击选词、三击选行），松开时复制，中键粘贴
+// 点击虚拟键盘的按键与手柄按 A 相同
+func (a *App) handleMouseButton(e *sdl.MouseButtonEvent) {
+	if a.reportMouseButton(e) {
+		return
+	}
+	if e.Button == sdl.BUTTON_LEFT && e.Type == sdl.MOUSEBUTTONDOWN {
+		if row, col, ok := a.keyAt(e.X, e.Y); ok {
+			a.selectedRow, a.selectedCol = row, col
+			a.DealwithInput("")
+			return
+		}
+	}
+	inTerminal := a.inTerminal(e.Y)
+	switch e.Button {
+	case sdl.BUTTON_LEFT:
+		if e.Type == sdl.MOUSEBUTTONDOWN {
+			if !inTerminal {
+				return
+			}
+			p := a.cellAt(e.X, e.Y)
+			unit := selectCells
+			switch {
+			case e.Clicks == 2:
+				unit = selectWords
+			case e.Clicks >= 3:
+				unit = selectLines
+			}
+			a.setSelection(p, p, unit)
+			a.selection.dragging = true
+			return
+		}
+		if a.selection.dragging {
+			a.selection.dragging = false
+			// 单击没有拖动时不保留选区
+			if a.selection.unit == selectCells && a.selection.anchor == a.selection.head {
+				a.clearSelection()
+				return
+			}
+			a.copySelection()
+		}
+	case sdl.BUTTON_MIDDLE:
+		if e.Type == sdl.MOUSEBUTTONDOWN && inTerminal {
+			a.pasteClipboard()
+		}
+	}
+}
+
+// handleMouseMotion 程序请求移动事件时报告给程序，否则拖动时更新选区，拖出终端上下边缘时滚动视图
+func (a *App) handleMouseMotion(e *sdl.MouseMotionEvent) {
+	if !a.selection.dragging && a.reportMouseMotion(e) {
+		return
+	}
+	if !a.selection.dragging {
+		return
+	}
+	if int(e.Y) < a.Cfg.title_bar_height {
+		a.terminal.ScrollView(1)
+	} else if int(e.Y) >= a.Cfg.terminal_height {
+		a.terminal.ScrollView(-1)
+	}
+	a.setSelection(a.selection.anchor, a.cellAt(e.X, e.Y), a.selection.unit)
+}
+
+// handleMouseWheel 程序请求鼠标事件时报告给程序，否则滚动查看历史
+func (a *App) handleMouseWheel(e *sdl.MouseWheelEvent) {
+	if a.reportMouseWheel(e) {
+		return
+	}
+	a.terminal.ScrollView(3 * int(e.Y))
+}
+
+// toggleGamepadSelection 进入或退出手柄选择模式，选择光标从终端光标处开始
+func (a *App) toggleGamepadSelection() {
+	if a.selection.gamepadMode {
+		a.clearSelection()
+		return
+	}
+	x, y := a.terminal.Cursor()
+	cols, rows := a.terminal.Size()
+	p := vt.Point{Line: a.terminal.Lines() - rows + y, Col: min(x, cols-1)}
+	a.selection = selection{gamepadMode: true}
+	a.setSelection(p, p, selectCells)
+}
+
+// moveGamepadSelection 移动选择光标，设置起点前起点跟随光标；光标移出视图时滚动
+func (a *App) moveGamepadSelection(deltaLine, deltaCol int) {
+	sel := &a.selection
+	cols, rows := a.terminal.Size()
+	head := sel.head
+	head.Line = max(a.terminal.FirstLine(), min(a.terminal.Lines()-1, head.Line+deltaLine))
+	head.Col = max(0, min(cols-1, head.Col+deltaCol))
+	anchor := sel.anchor
+	if !sel.marked {
+		anchor = head
+	}
+	a.setSelection(anchor, head, sel.unit)
+
+	// 保证光标所在行可见
+	top := a.viewTop()
+	if head.Line < top {
+		a.terminal.ScrollView(top - head.Line)
+	} else if head.Line >= top+rows {
+		a.terminal.ScrollView(top + rows - 1 - head.Line)
+	}
+}
+
+// handleSelectionButton 手柄选择模式下的按键：
+// 方向键移动，A 设置起点、再按复制并退出，LB 选词，RB 选行，X 粘贴，B 退出
+func (a *App) handleSelectionButton(button sdl.GameControllerButton) {
+	sel := &a.selection
+	switch button {
+	case sdl.CONTROLLER_BUTTON_DPAD_UP:
+		a.moveGamepadSelection(-1, 0)
+	case sdl.CONTROLLER_BUTTON_DPAD_DOWN:
+		a.moveGamepadSelection(1, 0)
+	case sdl.CONTROLLER_BUTTON_DPAD_LEFT:
+		a.moveGamepadSelection(0, -1)
+	case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
+		a.moveGamepadSelection(0, 1)
+	case sdl.CONTROLLER_BUTTON_A:
+		if !sel.marked {
+			sel.marked = true
+			return
+		}
+		a.copySelection()
+		a.clearSelection()
+	case sdl.CONTROLLER_BUTTON_LEFTSHOULDER:
+		sel.marked = true
+		a.setSelection(sel.head, sel.head, selectWords)
+	case sdl.CONTROLLER_BUTTON_RIGHTSHOULDER:
+		sel.marked = true
+		a.setSelection(sel.head, sel.head, selectLines)
+	case sdl.CONTROLLER_BUTTON_X:
+		a.clearSelection()
+		a.pasteClipboard()
+	case sdl.CONTROLLER_BUTTON_B, sdl.CONTROLLER_BUTTON_RIGHTSTICK:
+		a.clearSelection()
+	}
+}
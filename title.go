@@ -0,0 +1,44 @@
+package main
+
+import "github.com/veandco/go-sdl2/sdl"
+
+const defaultWindowTitle = "VTerm"
+
+// updateTitle 终端标题变化时同步到 SDL 窗口
+func (a *App) updateTitle() {
+	title := a.terminal.Title()
+	if title == a.title {
+		return
+	}
+	a.title = title
+	a.redraw = true
+	if title == "" {
+		a.window.SetTitle(defaultWindowTitle)
+	} else {
+		a.window.SetTitle(title)
+	}
+}
+
+// renderTitleBar 在终端区域上方绘制标题栏，全屏运行时看不到窗口标题
+func (a *App) renderTitleBar() {
+	if a.Cfg.title_bar_height == 0 {
+		return
+	}
+	barRect := sdl.Rect{X: 0, Y: 0, W: int32(a.Cfg.Window_Width), H: int32(a.Cfg.title_bar_height)}
+	a.renderer.SetDrawColor(50, 50, 50, 255)
+	a.renderer.FillRect(&barRect)
+	a.renderer.SetDrawColor(80, 80, 80, 255)
+	a.renderer.DrawLine(0, barRect.H-1, barRect.W, barRect.H-1)
+
+	title := a.title
+	if title == "" {
+		title = defaultWindowTitle
+	}
+	// 超出宽度的标题截断显示
+	maxChars := (a.Cfg.Window_Width - 8) / a.Cfg.char_width
+	runes := []rune(title)
+	if len(runes) > maxChars && maxChars > 3 {
+		title = string(runes[:maxChars-3]) + "..."
+	}
+	a.renderText(title, 4, 2, 200, 200, 200)
+}
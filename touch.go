@@ -0,0 +1,153 @@
+package main
+
+import (
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	keyRepeatDelay    = 400 * time.Millisecond // 按住虚拟键盘按键后开始重复的延迟
+	keyRepeatInterval = 80 * time.Millisecond  // 按住按键时重复的间隔
+	tapSlop           = 10                     // 手指移动不超过这个距离（像素）仍算点击
+)
+
+// touchState 触摸屏的状态
+// 虚拟键盘上按下即输入，按住重复；终端区域单指点击获取焦点，双指上下滑动滚动历史
+type touchState struct {
+	fingers map[sdl.FingerID]touchFinger // 按在屏幕上的手指
+	// 按住的虚拟键盘按键
+	keyDown        bool
+	keyFinger      sdl.FingerID
+	keyRow, keyCol int
+	nextRepeat     time.Time
+	// 终端区域的手势
+	tapFinger       sdl.FingerID
+	tapStart        sdl.Point // 终端区域内单指按下的位置
+	tapping         bool      // 单指按下后还没有移动或加入其他手指，松开时算点击
+	scrollRemainder float32   // 双指滑动不足一行的距离（像素）
+}
+
+// touchFinger 一根按在屏幕上的手指
+type touchFinger struct {
+	pos        sdl.Point // 当前位置（像素）
+	inTerminal bool      // 按下时是否在终端区域内，只有终端区域内的双指滑动才滚动历史
+}
+
+// touchPoint 将触摸事件的归一化坐标换算为窗口坐标
+func (a *App) touchPoint(e *sdl.TouchFingerEvent) sdl.Point {
+	return sdl.Point{X: int32(e.X * float32(a.Cfg.Window_Width)), Y: int32(e.Y * float32(a.Cfg.Window_Height))}
+}
+
+// handleTouch 处理触摸事件
+func (a *App) handleTouch(e *sdl.TouchFingerEvent) {
+	t := &a.touch
+	if t.fingers == nil {
+		t.fingers = make(map[sdl.FingerID]touchFinger)
+	}
+	p := a.touchPoint(e)
+	switch e.Type {
+	case sdl.FINGERDOWN:
+		t.tapping = false
+		if row, col, ok := a.keyAt(p.X, p.Y); ok && !t.keyDown {
+			t.fingers[e.FingerID] = touchFinger{pos: p}
+			a.pressTouchKey(e.FingerID, row, col)
+			return
+		}
+		t.fingers[e.FingerID] = touchFinger{pos: p, inTerminal: a.inTerminal(p.Y)}
+		if len(t.fingers) == 1 && a.inTerminal(p.Y) {
+			t.tapFinger, t.tapStart, t.tapping = e.FingerID, p, true
+		}
+		t.scrollRemainder = 0
+	case sdl.FINGERMOTION:
+		finger, exists := t.fingers[e.FingerID]
+		if !exists {
+			return
+		}
+		finger.pos = p
+		t.fingers[e.FingerID] = finger
+		if t.keyDown && e.FingerID == t.keyFinger {
+			// 手指移出按键后取消按下
+			if row, col, ok := a.keyAt(p.X, p.Y); !ok || row != t.keyRow || col != t.keyCol {
+				t.keyDown = false
+			}
+			return
+		}
+		if t.tapping && (abs(p.X-t.tapStart.X) > tapSlop || abs(p.Y-t.tapStart.Y) > tapSlop) {
+			t.tapping = false
+		}
+		if len(t.fingers) == 2 && !t.keyDown && t.allInTerminal() {
+			// 两根手指各贡献一半的移动距离，向下滑动查看更早的内容
+			a.scrollByTouch(e.DY * float32(a.Cfg.Window_Height) / 2)
+		}
+	case sdl.FINGERUP:
+		delete(t.fingers, e.FingerID)
+		if t.keyDown && e.FingerID == t.keyFinger {
+			t.keyDown = false
+			return
+		}
+		if t.tapping && e.FingerID == t.tapFinger {
+			t.tapping = false
+			a.focusTerminal()
+		}
+	}
+}
+
+// allInTerminal 按在屏幕上的手指是否都是在终端区域内按下的
+func (t *touchState) allInTerminal() bool {
+	for _, finger := range t.fingers {
+		if !finger.inTerminal {
+			return false
+		}
+	}
+	return true
+}
+
+// pressTouchKey 按下虚拟键盘的按键：立即输入一次，按住后开始重复（大写锁定不重复）
+func (a *App) pressTouchKey(finger sdl.FingerID, row, col int) {
+	t := &a.touch
+	a.selectedRow, a.selectedCol = row, col
+	t.keyDown, t.keyFinger, t.keyRow, t.keyCol = true, finger, row, col
+	t.nextRepeat = time.Now().Add(keyRepeatDelay)
+	a.DealwithInput("")
+	if a.keyBoards[row][col] == BTN_CAPS {
+		t.keyDown = false
+	}
+}
+
+// tickKeyRepeat 按住虚拟键盘的按键时按间隔重复输入
+func (a *App) tickKeyRepeat() {
+	t := &a.touch
+	if !t.keyDown || time.Now().Before(t.nextRepeat) {
+		return
+	}
+	t.nextRepeat = time.Now().Add(keyRepeatInterval)
+	a.selectedRow, a.selectedCol = t.keyRow, t.keyCol
+	a.DealwithInput("")
+	a.redraw = true
+}
+
+// scrollByTouch 双指滑动 dy 像素，按行滚动视图，不足一行的部分累积到下次
+func (a *App) scrollByTouch(dy float32) {
+	t := &a.touch
+	t.scrollRemainder += dy
+	lines := int(t.scrollRemainder / float32(a.Cfg.char_height))
+	if lines != 0 {
+		t.scrollRemainder -= float32(lines * a.Cfg.char_height)
+		a.terminal.ScrollView(lines)
+	}
+}
+
+// focusTerminal 点击终端区域：窗口获得焦点并开始接收文字输入，取消选区
+func (a *App) focusTerminal() {
+	a.window.Raise()
+	sdl.StartTextInput()
+	a.clearSelection()
+}
+
+func abs(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,37 @@
+package vt
+
+// markDirty 标记一行需要重画
+func (e *Emulator) markDirty(y int) {
+	if y >= 0 && y < len(e.dirty) {
+		e.dirty[y] = true
+	}
+}
+
+// markDirtyRange 标记 [top, bottom] 行区间需要重画
+func (e *Emulator) markDirtyRange(top, bottom int) {
+	for y := max(0, top); y <= bottom && y < len(e.dirty); y++ {
+		e.dirty[y] = true
+	}
+}
+
+// markAllDirty 标记整个屏幕需要重画，用于切换屏幕和调整尺寸
+func (e *Emulator) markAllDirty() {
+	for y := range e.dirty {
+		e.dirty[y] = true
+	}
+}
+
+// HasDirty 是否有需要重画的行
+func (e *Emulator) HasDirty() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.viewDirty {
+		return true
+	}
+	for _, dirty := range e.dirty {
+		if dirty {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package vt
+
+// breakWideChar 如果x处的单元格属于一个宽字符，将宽字符的两半都清除为空格
+// 用于编辑操作的边界，避免留下没有占位符的宽字符或孤立的占位符
+func (e *Emulator) breakWideChar(row []Cell, x int) {
+	if x < 0 || x >= len(row) {
+		return
+	}
+	switch row[x].Width {
+	case 0:
+		row[x] = e.blankCell()
+		if x > 0 && row[x-1].Width == 2 {
+			row[x-1] = e.blankCell()
+		}
+	case 2:
+		row[x] = e.blankCell()
+		if x+1 < len(row) && row[x+1].Width == 0 {
+			row[x+1] = e.blankCell()
+		}
+	}
+}
+
+// insertChars 在光标处插入n个空白字符，右侧内容右移（ICH）
+func (e *Emulator) insertChars(n int) {
+	if e.cursorX >= e.screenWidth {
+		return
+	}
+	row := e.screenBuffer[e.cursorY]
+	x := e.cursorX
+	n = min(n, e.screenWidth-x)
+	e.markDirty(e.cursorY)
+
+	if row[x].Width == 0 {
+		e.breakWideChar(row, x)
+	}
+	copy(row[x+n:], row[x:e.screenWidth-n])
+	for i := x; i < x+n; i++ {
+		row[i] = e.blankCell()
+	}
+	// 被挤出右边界的占位符对应的宽字符也要清除
+	if row[e.screenWidth-1].Width == 2 {
+		row[e.screenWidth-1] = e.blankCell()
+	}
+}
+
+// deleteChars 删除光标处的n个字符，右侧内容左移，行尾补空白（DCH）
+func (e *Emulator) deleteChars(n int) {
+	if e.cursorX >= e.screenWidth {
+		return
+	}
+	row := e.screenBuffer[e.cursorY]
+	x := e.cursorX
+	n = min(n, e.screenWidth-x)
+	e.markDirty(e.cursorY)
+
+	if row[x].Width == 0 {
+		e.breakWideChar(row, x)
+	}
+	if x+n < e.screenWidth && row[x+n].Width == 0 {
+		e.breakWideChar(row, x+n)
+	}
+	copy(row[x:], row[x+n:])
+	for i := e.screenWidth - n; i < e.screenWidth; i++ {
+		row[i] = e.blankCell()
+	}
+}
+
+// eraseChars 将光标处开始的n个字符擦除为空白，光标不动（ECH）
+func (e *Emulator) eraseChars(n int) {
+	if e.cursorX >= e.screenWidth {
+		return
+	}
+	row := e.screenBuffer[e.cursorY]
+	x := e.cursorX
+	n = min(n, e.screenWidth-x)
+	e.markDirty(e.cursorY)
+
+	if row[x].Width == 0 {
+		e.breakWideChar(row, x)
+	}
+	if x+n < e.screenWidth && row[x+n].Width == 0 {
+		e.breakWideChar(row, x+n)
+	}
+	for i := x; i < x+n; i++ {
+		row[i] = e.blankCell()
+	}
+}
+
+// insertLines 在光标行插入n个空行，光标行及以下内容在滚动区域内下移（IL）
+func (e *Emulator) insertLines(n int) {
+	if e.cursorY < e.scrollTop || e.cursorY > e.scrollBottom {
+		return
+	}
+	e.shiftRowsDown(e.cursorY, e.scrollBottom, n)
+	e.cursorX = 0
+}
+
+// deleteLines 删除光标行开始的n行，下方内容在滚动区域内上移（DL）
+func (e *Emulator) deleteLines(n int) {
+	if e.cursorY < e.scrollTop || e.cursorY > e.scrollBottom {
+		return
+	}
+	e.shiftRowsUp(e.cursorY, e.scrollBottom, n)
+	e.cursorX = 0
+}
+
+// repeatChar 重复输出上一个可显示字符n次（REP）
+func (e *Emulator) repeatChar(n int) {
+	if e.lastChar == "" {
+		return
+	}
+	// 最多重复一整屏，避免异常参数导致长时间循环
+	n = min(n, e.screenWidth*e.screenHeight)
+	for i := 0; i < n; i++ {
+		e.printChar(e.lastChar)
+	}
+}
@@ -0,0 +1,607 @@
+// Package vt 实现与 pty 和 SDL 无关的终端模拟器：解析输出字节流，维护屏幕、历史和光标，
+// 界面层通过快照读取屏幕内容
+package vt
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// Cell 屏幕上的一个单元格
+type Cell struct {
+	Char  string
+	Width int   // 字符显示宽度：1为半角，2为全角，0为宽字符的占位符
+	Style Style // 颜色和显示属性
+}
+
+// Modes 终端当前的模式状态
+type Modes struct {
+	AltScreen      bool // 是否处于备用屏幕
+	BracketedPaste bool // 粘贴的内容是否需要用 ESC [200~ 和 ESC [201~ 包围 (DECSET 2004)
+	AppCursorKeys  bool // 方向键和 Home/End 发送 ESC O 开头的序列 (DECCKM)
+	AppKeypad      bool // 小键盘发送 ESC O 开头的序列 (DECKPAM/DECKPNM)
+	// 按键编码的扩展协议，见 EncodeKey
+	ModifyOtherKeys int // xterm modifyOtherKeys 级别 (CSI > 4 ; n m)，0 为关闭
+	KittyKeyboard   int // 当前屏幕 kitty 键盘协议的标志 (CSI > flags u)，0 为关闭
+	// 鼠标报告，见 EncodeMouse
+	MouseTracking MouseTracking // 鼠标跟踪模式，MouseTrackingNone 时鼠标事件由界面自己处理
+	MouseSGR      bool          // 使用 SGR 编码报告鼠标事件 (DECSET 1006)
+}
+
+// Emulator 终端模拟器，通过 Write 接收子进程的输出，所有方法都可以并发调用
+type Emulator struct {
+	mutex        sync.RWMutex
+	maxLines     int
+	cursorX      int
+	cursorY      int
+	screenBuffer [][]Cell
+	screenWidth  int
+	screenHeight int
+	parser       *Parser
+	pen          Style  // 当前SGR画笔样式，写入字符时使用
+	lastChar     string // 上一个输出的可显示字符，供 REP 使用
+	dirty        []bool // 每行是否有变化需要重画
+	// 备用屏幕
+	primaryBuffer [][]Cell // 主屏幕缓冲区
+	altBuffer     [][]Cell // 备用屏幕缓冲区，全屏程序使用，内容不进入滚动历史
+	// 软换行标记：wrapped[y] 为 true 表示第y行因自动换行延续到下一行，调整尺寸时据此重排
+	wrapped        []bool
+	primaryWrapped []bool
+	altWrapped     []bool
+	altScreen      bool        // 当前是否处于备用屏幕
+	bracketedPaste bool        // 括号粘贴模式 (DECSET 2004)
+	appCursorKeys  bool        // 应用光标键模式 (DECCKM)
+	appKeypad      bool        // 应用小键盘模式 (DECKPAM)
+	primarySaved   savedCursor // 主屏幕保存的光标
+	altSaved       savedCursor // 备用屏幕保存的光标
+	// 按键编码协议
+	modifyOtherKeys int
+	primaryKitty    []int // 主屏幕的 kitty 键盘协议标志栈，栈顶为当前标志
+	altKitty        []int // 备用屏幕的 kitty 键盘协议标志栈
+	// 鼠标报告
+	mouseTracking MouseTracking
+	mouseSGR      bool
+	// 滚动区域 (DECSTBM)，闭区间
+	scrollTop    int
+	scrollBottom int
+	// 滚动
+	history    scrollback // 滚出主屏幕的历史行
+	viewOffset int        // 视图偏移量（向上滚动了多少行），只影响快照，不改变屏幕内容
+	viewDirty  bool       // 视图偏移或其中的历史行变化，快照需要整屏重画
+	// 窗口标题 (OSC 0/1/2)
+	title      string
+	iconName   string
+	titleStack []titleEntry
+	// 需要回复给子进程的内容（如查询的应答），由 Replies 取出后写入 pty
+	replies []byte
+}
+
+// NewEmulator 创建指定列数和行数的终端模拟器
+func NewEmulator(cols, rows int) *Emulator {
+	cols, rows = max(1, cols), max(1, rows)
+	e := &Emulator{
+		maxLines:       rows,
+		screenWidth:    cols,
+		screenHeight:   rows,
+		scrollTop:      0,
+		scrollBottom:   rows - 1,
+		primaryBuffer:  newScreenBuffer(cols, rows),
+		altBuffer:      newScreenBuffer(cols, rows),
+		primaryWrapped: make([]bool, rows),
+		altWrapped:     make([]bool, rows),
+		history:        newScrollback(DefaultScrollbackLines),
+		dirty:          make([]bool, rows),
+	}
+	e.markAllDirty()
+	e.screenBuffer = e.primaryBuffer
+	e.wrapped = e.primaryWrapped
+	e.parser = NewParser(e)
+	return e
+}
+
+// Write 解析子进程输出的字节流并更新屏幕，实现 io.Writer
+func (e *Emulator) Write(p []byte) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	cursorX, cursorY := e.cursorX, e.cursorY
+	e.parser.Feed(p)
+	// 光标移动时原来和现在所在的行都需要重画
+	if e.cursorX != cursorX || e.cursorY != cursorY {
+		e.markDirty(cursorY)
+		e.markDirty(e.cursorY)
+	}
+	return len(p), nil
+}
+
+// Size 返回终端的列数和行数
+func (e *Emulator) Size() (cols, rows int) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.screenWidth, e.screenHeight
+}
+
+// Cursor 返回光标位置（从0开始），光标在行尾等待换行时 x 等于列数
+func (e *Emulator) Cursor() (x, y int) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.cursorX, e.cursorY
+}
+
+// Cell 返回当前显示的第y行第x列的单元格，超出范围时返回空白单元格
+func (e *Emulator) Cell(x, y int) Cell {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if y < 0 || y >= e.screenHeight || x < 0 || x >= e.screenWidth {
+		return Cell{Char: " ", Width: 1}
+	}
+	return e.screenBuffer[y][x]
+}
+
+// Modes 返回终端当前的模式状态
+func (e *Emulator) Modes() Modes {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return Modes{
+		AltScreen:      e.altScreen,
+		BracketedPaste: e.bracketedPaste,
+		AppCursorKeys:  e.appCursorKeys,
+		AppKeypad:      e.appKeypad,
+
+		ModifyOtherKeys: e.modifyOtherKeys,
+		KittyKeyboard:   e.kittyFlags(),
+
+		MouseTracking: e.mouseTracking,
+		MouseSGR:      e.mouseSGR,
+	}
+}
+
+// Replies 取出并清空需要回复给子进程的内容，调用方在 Write 之后将其写入 pty
+func (e *Emulator) Replies() []byte {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	replies := e.replies
+	e.replies = nil
+	return replies
+}
+
+// reply 追加一条回复
+func (e *Emulator) reply(s string) {
+	e.replies = append(e.replies, s...)
+}
+
+// StringWidth 返回文本在终端中占用的列数，宽度计算与写入终端的字符一致
+func StringWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += getCharWidth(string(r))
+	}
+	return width
+}
+
+// getCharWidth 返回字符的显示宽度
+func getCharWidth(char string) int {
+	if len(char) == 0 {
+		return 0
+	}
+
+	// 获取第一个rune
+	r, _ := utf8.DecodeRuneInString(char)
+
+	// 简单的字符宽度判断
+	// 中文字符范围
+	if r >= 0x4e00 && r <= 0x9fff {
+		return 2
+	}
+	// 中文标点符号
+	if r >= 0x3000 && r <= 0x303f {
+		return 2
+	}
+	// 全角字符
+	if r >= 0xff00 && r <= 0xffef {
+		return 2
+	}
+	// 其他一些常见的宽字符
+	if r >= 0x1100 && r <= 0x11ff { // 韩文字母
+		return 2
+	}
+	if r >= 0x2e80 && r <= 0x2eff { // CJK 部首补充
+		return 2
+	}
+	if r >= 0x2f00 && r <= 0x2fdf { // 康熙部首
+		return 2
+	}
+	if r >= 0x3100 && r <= 0x312f { // 注音符号
+		return 2
+	}
+	if r >= 0x3200 && r <= 0x32ff { // 带圈字符
+		return 2
+	}
+	if r >= 0x3400 && r <= 0x4dbf { // CJK 扩展A
+		return 2
+	}
+	if r >= 0xac00 && r <= 0xd7af { // 韩文音节
+		return 2
+	}
+	if r >= 0xf900 && r <= 0xfaff { // CJK 兼容汉字
+		return 2
+	}
+
+	return 1 // 默认半角
+}
+
+// SetScrollback 设置最多保存的历史行数：0 不保存，负数表示不限制（以 MaxScrollbackLines 为上限）
+// 容量变小时丢弃最老的行
+func (e *Emulator) SetScrollback(lines int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	old := e.history
+	e.history = newScrollback(lines)
+	discard := max(0, old.len()-e.history.limit)
+	e.history.dropped = old.dropped + discard
+	for i := discard; i < old.len(); i++ {
+		line := old.at(i)
+		e.history.push(line.cells, line.wrapped)
+	}
+	e.viewOffset = min(e.viewOffset, e.history.len())
+	e.viewDirty = true
+}
+
+// pushHistory 将滚出屏幕的一行保存到历史
+// 向上滚动查看历史时视图跟随原来的内容，不会被新的输出推走
+func (e *Emulator) pushHistory(line []Cell, wrapped bool) {
+	e.history.push(line, wrapped)
+	if e.viewOffset > 0 {
+		e.viewOffset = min(e.viewOffset+1, e.history.len())
+		e.viewDirty = true
+	}
+}
+
+// ScrollView 向上（delta 为正）或向下滚动查看历史，最多滚到最老的一行
+func (e *Emulator) ScrollView(delta int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// 备用屏幕没有滚动历史
+	if e.altScreen {
+		return
+	}
+
+	offset := max(0, min(e.history.len(), e.viewOffset+delta))
+	if offset != e.viewOffset {
+		e.viewOffset = offset
+		e.viewDirty = true
+	}
+}
+
+// ScrollToBottom 回到最新的内容
+func (e *Emulator) ScrollToBottom() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.viewOffset > 0 {
+		e.viewOffset = 0
+		e.viewDirty = true
+	}
+}
+
+// ViewOffset 返回当前向上滚动的行数，0 表示显示最新的内容
+func (e *Emulator) ViewOffset() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.viewOffset
+}
+
+// Print 处理解析器输出的可显示字符（包括UTF-8字符）
+func (e *Emulator) Print(r rune) {
+	char := string(r)
+	if isPrintableChar(char) {
+		e.printChar(char)
+	}
+}
+
+// Execute 处理 C0/C1 控制字符
+func (e *Emulator) Execute(b byte) {
+	switch b {
+	case '\n', '\v', '\f':
+		e.lineFeed()
+	case '\b':
+		e.handleBackspace()
+	case '\r':
+		e.cursorX = 0
+	case '\t':
+		nextTab := ((e.cursorX / 8) + 1) * 8
+		e.markDirty(e.cursorY)
+		for e.cursorX < nextTab && e.cursorX < e.screenWidth {
+			e.screenBuffer[e.cursorY][e.cursorX] = Cell{Char: " ", Width: 1}
+			e.cursorX++
+		}
+	case 0x84: // IND
+		e.lineFeed()
+	case 0x85: // NEL
+		e.cursorX = 0
+		e.lineFeed()
+	case 0x8d: // RI
+		e.reverseIndex()
+	}
+}
+
+// printChar 在光标处写入一个可显示字符并前移光标，必要时自动换行
+func (e *Emulator) printChar(char string) {
+	charWidth := getCharWidth(char)
+	e.lastChar = char
+
+	// 检查是否有足够空间显示该字符
+	if e.cursorX+charWidth > e.screenWidth {
+		// 宽字符放不下时行尾剩余的格子用填充单元格占位，重排时跳过
+		if e.cursorX < e.screenWidth {
+			e.screenBuffer[e.cursorY][e.cursorX] = wrapPaddingCell
+			e.markDirty(e.cursorY)
+		}
+		// 换行，并标记为软换行
+		e.wrapped[e.cursorY] = true
+		e.cursorX = 0
+		e.lineFeed()
+	}
+
+	if e.cursorY < e.screenHeight {
+		// 覆盖宽字符的一半时先清除整个宽字符
+		row := e.screenBuffer[e.cursorY]
+		e.breakWideChar(row, e.cursorX)
+		if charWidth == 2 {
+			e.breakWideChar(row, e.cursorX+1)
+		}
+
+		// 写入字符
+		e.screenBuffer[e.cursorY][e.cursorX] = Cell{Char: char, Width: charWidth, Style: e.pen}
+		e.markDirty(e.cursorY)
+
+		// 如果是宽字符，需要在下一个位置标记为占位符
+		if charWidth == 2 && e.cursorX+1 < e.screenWidth {
+			e.screenBuffer[e.cursorY][e.cursorX+1] = Cell{Char: "", Width: 0, Style: e.pen} // 占位符
+		}
+
+		e.cursorX += charWidth
+	}
+}
+
+func isPrintableChar(char string) bool {
+	if len(char) == 0 {
+		return false
+	}
+
+	// 对于单字节字符，检查ASCII范围
+	if len(char) == 1 {
+		b := char[0]
+		// 排除所有控制字符 (0-31 和 127)
+		if b < 32 || b == 127 {
+			return false
+		}
+		return true
+	}
+
+	// 对于多字节字符（UTF-8），获取第一个rune
+	r, _ := utf8.DecodeRuneInString(char)
+
+	// 排除Unicode控制字符
+	if r < 32 || (r >= 127 && r <= 159) {
+		return false
+	}
+
+	// 排除特殊的Unicode控制字符
+	if r == 0xFEFF || // BOM
+		(r >= 0x200B && r <= 0x200F) || // Zero-width spaces
+		(r >= 0x2028 && r <= 0x2029) || // Line/Paragraph separators
+		(r >= 0xE000 && r <= 0xF8FF) { // Private use area
+		return false
+	}
+
+	return true
+}
+
+// handleBackspace 光标左移一列
+// BS 本身不擦除字符：readline 等程序用它移动光标，删除时会自行输出空格或 CSI K
+func (e *Emulator) handleBackspace() {
+	if e.cursorX > 0 {
+		e.cursorX = min(e.cursorX, e.screenWidth) - 1
+	}
+}
+
+// CsiDispatch 处理 CSI 序列
+func (e *Emulator) CsiDispatch(params Params, intermediates []byte, final byte) {
+	switch string(intermediates) {
+	case "":
+	case "?":
+		// 私有模式 (DECSET/DECRST)
+		switch final {
+		case 'h':
+			e.setPrivateModes(params, true)
+		case 'l':
+			e.setPrivateModes(params, false)
+		case 'u':
+			// 查询 kitty 键盘协议的标志
+			e.kittyKeyboard('?', params)
+		}
+		return
+	case ">", "<", "=":
+		switch {
+		case final == 'u':
+			e.kittyKeyboard(intermediates[0], params)
+		case final == 'm' && intermediates[0] == '>':
+			e.setModifyOtherKeys(params)
+		}
+		return
+	default:
+		return
+	}
+
+	n := params.Get(0, 1)
+	switch final {
+	case 'H', 'f':
+		e.setCursorPosition(params.Get(0, 1)-1, params.Get(1, 1)-1)
+	case 'A':
+		// 光标在滚动区域内时不越过上边距
+		top := 0
+		if e.cursorY >= e.scrollTop {
+			top = e.scrollTop
+		}
+		e.cursorY = max(top, e.cursorY-n)
+	case 'B':
+		// 光标在滚动区域内时不越过下边距
+		bottom := e.screenHeight - 1
+		if e.cursorY <= e.scrollBottom {
+			bottom = e.scrollBottom
+		}
+		e.cursorY = min(bottom, e.cursorY+n)
+	case 'C':
+		e.cursorX = min(e.screenWidth-1, e.cursorX+n)
+	case 'D':
+		e.cursorX = max(0, min(e.cursorX, e.screenWidth-1)-n)
+	case 'E': // CNL
+		e.setCursorPosition(e.cursorY+n, 0)
+	case 'F': // CPL
+		e.setCursorPosition(e.cursorY-n, 0)
+	case 'G', '`': // CHA/HPA
+		e.setCursorPosition(e.cursorY, n-1)
+	case 'd': // VPA
+		e.setCursorPosition(n-1, e.cursorX)
+	case 'J':
+		e.clearScreen(params.Get(0, 0))
+	case 'K':
+		e.clearLine(params.Get(0, 0))
+	case '@':
+		e.insertChars(n)
+	case 'P':
+		e.deleteChars(n)
+	case 'L':
+		e.insertLines(n)
+	case 'M':
+		e.deleteLines(n)
+	case 'X':
+		e.eraseChars(n)
+	case 'b':
+		e.repeatChar(n)
+	case 'm':
+		e.setGraphicsRendition(params)
+	case 'r':
+		e.setScrollRegion(params)
+	case 'S':
+		e.scrollRegionUp(n)
+	case 'T':
+		// 多个参数的 CSI T 是鼠标高亮跟踪，忽略
+		if len(params) == 1 {
+			e.scrollRegionDown(n)
+		}
+	case 't':
+		e.windowOps(params)
+	case 's':
+		e.saveCursor()
+	case 'u':
+		e.restoreCursor()
+	}
+}
+
+// EscDispatch 处理 ESC 序列
+func (e *Emulator) EscDispatch(intermediates []byte, final byte) {
+	// 字符集指定（如 ESC ( B）等带中间字节的序列暂不处理
+	if len(intermediates) > 0 {
+		return
+	}
+	switch final {
+	case 'D': // IND
+		e.lineFeed()
+	case 'E': // NEL
+		e.cursorX = 0
+		e.lineFeed()
+	case 'M': // RI
+		e.reverseIndex()
+	case '7': // DECSC
+		e.saveCursor()
+	case '8': // DECRC
+		e.restoreCursor()
+	case '=': // DECKPAM
+		e.appKeypad = true
+	case '>': // DECKPNM
+		e.appKeypad = false
+	}
+}
+
+// OscDispatch 处理 OSC 字符串
+func (e *Emulator) OscDispatch(params [][]byte, bellTerminated bool) {
+	switch string(params[0]) {
+	case "0", "1", "2":
+		e.handleTitleOsc(params)
+	}
+}
+
+// DcsHook 处理 DCS 序列，目前不支持任何 DCS 功能
+func (e *Emulator) DcsHook(params Params, intermediates []byte, final byte) {}
+
+// DcsPut 接收 DCS 数据
+func (e *Emulator) DcsPut(r rune) {}
+
+// DcsUnhook 结束 DCS 序列
+func (e *Emulator) DcsUnhook() {}
+
+// setCursorPosition 将光标移动到指定行列（从0开始）
+func (e *Emulator) setCursorPosition(row, col int) {
+	e.cursorY = max(0, min(e.screenHeight-1, row))
+	e.cursorX = max(0, min(e.screenWidth-1, col))
+}
+
+func (e *Emulator) clearScreen(n int) {
+	switch n {
+	case 0:
+		e.markDirtyRange(e.cursorY, e.screenHeight-1)
+		for y := e.cursorY; y < e.screenHeight; y++ {
+			startX := 0
+			if y == e.cursorY {
+				startX = min(e.cursorX, e.screenWidth)
+			}
+			for x := startX; x < e.screenWidth; x++ {
+				e.screenBuffer[y][x] = e.blankCell()
+			}
+		}
+	case 1:
+		e.markDirtyRange(0, e.cursorY)
+		for y := 0; y <= e.cursorY; y++ {
+			endX := e.screenWidth
+			if y == e.cursorY {
+				endX = min(e.cursorX+1, e.screenWidth)
+			}
+			for x := 0; x < endX; x++ {
+				e.screenBuffer[y][x] = e.blankCell()
+			}
+		}
+	case 2:
+		for y := 0; y < e.screenHeight; y++ {
+			for x := 0; x < e.screenWidth; x++ {
+				e.screenBuffer[y][x] = e.blankCell()
+			}
+			e.wrapped[y] = false
+		}
+		// 与 xterm 一致，ED 2 不移动光标
+		e.markAllDirty()
+	}
+}
+
+func (e *Emulator) clearLine(n int) {
+	e.markDirty(e.cursorY)
+	switch n {
+	case 0:
+		for x := e.cursorX; x < e.screenWidth; x++ {
+			e.screenBuffer[e.cursorY][x] = e.blankCell()
+		}
+	case 1:
+		for x := 0; x <= min(e.cursorX, e.screenWidth-1); x++ {
+			e.screenBuffer[e.cursorY][x] = e.blankCell()
+		}
+	case 2:
+		for x := 0; x < e.screenWidth; x++ {
+			e.screenBuffer[e.cursorY][x] = e.blankCell()
+		}
+	}
+}
+
+// blankCell 返回擦除后的空白单元格，背景色沿用当前画笔（BCE）
+func (e *Emulator) blankCell() Cell {
+	return Cell{Char: " ", Width: 1, Style: Style{Bg: e.pen.Bg}}
+}
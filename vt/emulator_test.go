@@ -0,0 +1,320 @@
+package vt
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// screenText 返回第y行的文本，宽字符的占位符不输出，行尾空白去掉
+func screenText(e *Emulator, y int) string {
+	cols, _ := e.Size()
+	var line strings.Builder
+	for x := 0; x < cols; x++ {
+		cell := e.Cell(x, y)
+		if cell.Width > 0 {
+			line.WriteString(cell.Char)
+		}
+	}
+	return strings.TrimRight(line.String(), " ")
+}
+
+func TestWriteText(t *testing.T) {
+	e := NewEmulator(10, 3)
+	fmt.Fprint(e, "hello\r\n中文ab")
+
+	if got := screenText(e, 0); got != "hello" {
+		t.Errorf("row 0 = %q, want %q", got, "hello")
+	}
+	if got := screenText(e, 1); got != "中文ab" {
+		t.Errorf("row 1 = %q, want %q", got, "中文ab")
+	}
+	if cell := e.Cell(0, 1); cell.Width != 2 {
+		t.Errorf("wide char width = %d, want 2", cell.Width)
+	}
+	if x, y := e.Cursor(); x != 6 || y != 1 {
+		t.Errorf("cursor = (%d, %d), want (6, 1)", x, y)
+	}
+}
+
+func TestWideCharWrapPadding(t *testing.T) {
+	e := NewEmulator(5, 3)
+	fmt.Fprint(e, "abcd中")
+
+	if got := screenText(e, 0); got != "abcd" {
+		t.Errorf("row 0 = %q, want %q", got, "abcd")
+	}
+	if got := screenText(e, 1); got != "中" {
+		t.Errorf("row 1 = %q, want %q", got, "中")
+	}
+
+	// 变宽后填充单元格被丢弃，宽字符接回原来的行
+	e.Resize(10, 3)
+	if got := screenText(e, 0); got != "abcd中" {
+		t.Errorf("after resize row 0 = %q, want %q", got, "abcd中")
+	}
+}
+
+func TestAltScreenMode(t *testing.T) {
+	e := NewEmulator(10, 3)
+	fmt.Fprint(e, "shell")
+	fmt.Fprint(e, "\x1b[?1049h")
+	if !e.Modes().AltScreen {
+		t.Fatal("alt screen not active after CSI ? 1049 h")
+	}
+	if got := screenText(e, 0); got != "" {
+		t.Errorf("alt screen row 0 = %q, want empty", got)
+	}
+
+	fmt.Fprint(e, "vim\x1b[?1049l")
+	if e.Modes().AltScreen {
+		t.Fatal("alt screen still active after CSI ? 1049 l")
+	}
+	if got := screenText(e, 0); got != "shell" {
+		t.Errorf("primary row 0 = %q, want %q", got, "shell")
+	}
+	if x, y := e.Cursor(); x != 5 || y != 0 {
+		t.Errorf("cursor = (%d, %d), want (5, 0)", x, y)
+	}
+}
+
+func TestSnapshotDirtyRows(t *testing.T) {
+	e := NewEmulator(10, 4)
+	var s Snapshot
+	e.Snapshot(&s)
+	for y := range s.Dirty {
+		s.Dirty[y] = false
+	}
+	if e.HasDirty() {
+		t.Fatal("emulator still dirty after snapshot")
+	}
+
+	fmt.Fprint(e, "\x1b[3;1Hx")
+	e.Snapshot(&s)
+	for y, dirty := range s.Dirty {
+		// 光标从第0行移到第2行，两行都要重画
+		want := y == 0 || y == 2
+		if dirty != want {
+			t.Errorf("row %d dirty = %v, want %v", y, dirty, want)
+		}
+	}
+	if s.Rows[2][0].Char != "x" {
+		t.Errorf("snapshot cell = %q, want %q", s.Rows[2][0].Char, "x")
+	}
+}
+
+// TestConcurrentAccess 配合 go test -race 检查解析和渲染、调整尺寸之间的数据竞争
+func TestConcurrentAccess(t *testing.T) {
+	e := NewEmulator(20, 5)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(e, "\x1b[3%dmline %d 中文\x1b]0;title %d\x07\r\n", i%8, i, i)
+		}
+	}()
+
+	var s Snapshot
+	for i := 0; i < 200; i++ {
+		e.Snapshot(&s)
+		e.ScrollView(1)
+		e.Title()
+		e.Cursor()
+		if i%50 == 0 {
+			e.Resize(15+i%10, 4+i%3)
+		}
+	}
+	wg.Wait()
+}
+
+func TestSubParamLimit(t *testing.T) {
+	e := NewEmulator(10, 2)
+	// 超长的子参数列表被截断，序列仍然正常结束
+	fmt.Fprint(e, "\x1b[1"+strings.Repeat(":2", 10000)+"mX")
+	if got := len(e.parser.values); got > maxValues {
+		t.Errorf("parser kept %d values, want at most %d", got, maxValues)
+	}
+	if got := screenText(e, 0); got != "X" {
+		t.Errorf("row 0 = %q, want %q", got, "X")
+	}
+}
+
+func TestScrollbackRing(t *testing.T) {
+	e := NewEmulator(8, 2)
+	e.SetScrollback(3)
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(e, "line%d\r\n", i)
+	}
+
+	// 屏幕上是 line6 和空行，历史只保留最近的 3 行
+	if got := e.history.len(); got != 3 {
+		t.Fatalf("history len = %d, want 3", got)
+	}
+	for i, want := range []string{"line3", "line4", "line5"} {
+		line := e.history.at(i)
+		if got := rowText(line.cells); got != want {
+			t.Errorf("history[%d] = %q, want %q", i, got, want)
+		}
+	}
+
+	// 缩小容量时保留最新的行
+	e.SetScrollback(1)
+	if got := rowText(e.history.at(0).cells); e.history.len() != 1 || got != "line5" {
+		t.Errorf("after shrink history = %d lines, first %q", e.history.len(), got)
+	}
+
+	e.SetScrollback(0)
+	fmt.Fprint(e, "more\r\n\r\n")
+	if got := e.history.len(); got != 0 {
+		t.Errorf("history len with scrollback 0 = %d, want 0", got)
+	}
+}
+
+func TestScrollbackKeepsWrappedSpaces(t *testing.T) {
+	e := NewEmulator(6, 1)
+	// "hello " 恰好写满一行后软换行，行尾的空格是内容的一部分
+	fmt.Fprint(e, "hello world\r\n")
+	e.Resize(12, 1)
+
+	if got := e.history.len(); got != 1 {
+		t.Fatalf("history len = %d, want 1", got)
+	}
+	if got := rowText(e.history.at(0).cells); got != "hello world" {
+		t.Errorf("history[0] = %q, want %q", got, "hello world")
+	}
+}
+
+func TestScrollViewSnapshot(t *testing.T) {
+	e := NewEmulator(8, 3)
+	fmt.Fprint(e, "1\r\n2\r\n3\r\n4\r\n5")
+
+	var s Snapshot
+	e.ScrollView(2)
+	e.Snapshot(&s)
+	want := []string{"1", "2", "3"}
+	for y, row := range s.Rows {
+		if got := strings.TrimRight(rowText(row), " "); got != want[y] {
+			t.Errorf("scrolled row %d = %q, want %q", y, got, want[y])
+		}
+	}
+	if s.ViewOffset != 2 || s.HistoryLines != 2 || s.CursorY != 4 {
+		t.Errorf("snapshot offset=%d history=%d cursorY=%d, want 2 2 4", s.ViewOffset, s.HistoryLines, s.CursorY)
+	}
+
+	// 滚动期间的新输出写入屏幕，视图停留在原来的内容上
+	fmt.Fprint(e, "\r\n6")
+	e.Snapshot(&s)
+	if got := strings.TrimRight(rowText(s.Rows[0]), " "); got != "1" || s.ViewOffset != 3 {
+		t.Errorf("row 0 after output = %q (offset %d), want %q (offset 3)", got, s.ViewOffset, "1")
+	}
+
+	e.ScrollToBottom()
+	e.Snapshot(&s)
+	want = []string{"4", "5", "6"}
+	for y, row := range s.Rows {
+		if got := strings.TrimRight(rowText(row), " "); got != want[y] {
+			t.Errorf("row %d after scroll to bottom = %q, want %q", y, got, want[y])
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	e := NewEmulator(12, 3)
+	fmt.Fprint(e, "error: one\r\nok\r\n中文 Error\r\nok\r\nerror two")
+
+	matches := e.Search(regexp.MustCompile("(?i)error"))
+	// 前两行在历史中，宽字符占两列
+	want := []Match{{Line: 0, Start: 0, End: 5}, {Line: 2, Start: 5, End: 10}, {Line: 4, Start: 0, End: 5}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Fatalf("matches = %v, want %v", matches, want)
+	}
+
+	e.ScrollToLine(0)
+	var s Snapshot
+	e.Snapshot(&s)
+	// 最老的一行无法居中，显示在第一行
+	if row := 0 - (s.HistoryLines - s.ViewOffset); row != 0 {
+		t.Errorf("line 0 shown at row %d, want 0", row)
+	}
+	e.ScrollToLine(4)
+	if got := e.ViewOffset(); got != 0 {
+		t.Errorf("view offset for screen line = %d, want 0", got)
+	}
+}
+
+func TestSearchAfterScrollbackWraps(t *testing.T) {
+	e := NewEmulator(8, 2)
+	e.SetScrollback(3)
+	fmt.Fprint(e, "a\r\nmark\r\nb\r\nc")
+	re := regexp.MustCompile("mark")
+	before := e.Search(re)
+
+	// 环形缓冲区满了之后丢弃最老的行，已有行的行号不变
+	fmt.Fprint(e, "\r\nd\r\ne")
+	after := e.Search(re)
+	if !reflect.DeepEqual(after, before) {
+		t.Fatalf("matches after wrap = %v, want %v", after, before)
+	}
+
+	e.ScrollToLine(before[0].Line)
+	var s Snapshot
+	e.Snapshot(&s)
+	row := before[0].Line - (s.FirstLine + s.HistoryLines - s.ViewOffset)
+	if row < 0 || row >= len(s.Rows) || strings.TrimRight(rowText(s.Rows[row]), " ") != "mark" {
+		t.Errorf("line %d shown at row %d, want the matched line on screen", before[0].Line, row)
+	}
+}
+
+func TestSelectionText(t *testing.T) {
+	e := NewEmulator(6, 3)
+	// 第一行软换行到第二行；宽字符在行尾放不下时留下换行填充
+	fmt.Fprint(e, "ls /tmp\r\nabc中文\r\nx")
+
+	tests := []struct {
+		from, to Point
+		want     string
+	}{
+		{Point{0, 3}, Point{1, 3}, "/tmp"},              // 软换行的行直接连接
+		{Point{0, 0}, Point{4, 5}, "ls /tmp\nabc中文\nx"}, // 换行填充和行尾空白不输出
+		{Point{2, 5}, Point{2, 4}, "中"},                 // 从宽字符的后半部分开始，起止可以颠倒
+	}
+	for _, tt := range tests {
+		if got := e.Text(tt.from, tt.to); got != tt.want {
+			t.Errorf("Text(%v, %v) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	from, to := e.WordAt(Point{0, 1})
+	if got := e.Text(from, to); got != "ls" {
+		t.Errorf("WordAt = %q, want %q", got, "ls")
+	}
+}
+
+func TestSelectionAfterScrollbackWraps(t *testing.T) {
+	e := NewEmulator(8, 2)
+	e.SetScrollback(3)
+	fmt.Fprint(e, "a\r\nb\r\nc\r\nd")
+	from, to := Point{Line: 1, Col: 0}, Point{Line: 2, Col: 0}
+	if got := e.Text(from, to); got != "b\nc" {
+		t.Fatalf("Text before wrap = %q, want %q", got, "b\nc")
+	}
+
+	// 历史丢弃最老的行后选区仍指向原来的内容，被丢弃的行不再有内容
+	fmt.Fprint(e, "\r\ne\r\nf")
+	if got := e.Text(from, to); got != "b\nc" {
+		t.Errorf("Text after wrap = %q, want %q", got, "b\nc")
+	}
+	if got, want := e.FirstLine(), 1; got != want {
+		t.Errorf("FirstLine = %d, want %d", got, want)
+	}
+	if got, want := e.Lines(), 6; got != want {
+		t.Errorf("Lines = %d, want %d", got, want)
+	}
+	if got := e.Text(Point{Line: 0, Col: 0}, Point{Line: 0, Col: 7}); got != "" {
+		t.Errorf("Text of dropped line = %q, want empty", got)
+	}
+}
@@ -0,0 +1,289 @@
+package vt
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成 testdata/golden 下的期望输出")
+
+// goldenCase 一个一致性测试用例：将字节流写入指定尺寸的终端，与 testdata/golden/<name>.golden 比较
+type goldenCase struct {
+	name       string
+	cols, rows int
+	input      string // 直接写入的字节流
+	stream     string // testdata/streams 下录制的真实程序输出，设置时忽略 input
+}
+
+var goldenCases = []goldenCase{
+	{
+		name: "cursor-movement", cols: 20, rows: 6,
+		// CUP、CUU/CUD/CUF/CUB 及越界裁剪，CR、LF、BS、TAB
+		input: "A\x1b[3;5HB\x1b[2AC\x1b[10CD\x1b[20DE\x1b[BF\tG\bH\x1b[99;99HZ\x1b[6;1Hx\r\ny",
+	},
+	{
+		name: "cursor-absolute", cols: 12, rows: 5,
+		// CHA/HPA 设置列，VPA 设置行，CNL/CPL 移到下/上若干行的行首
+		input: "\x1b[5Ga\x1b[3db\x1b[10`c\x1b[Ed\x1b[2Fe\x1b[99Gf\x1b[99dg",
+	},
+	{
+		name: "cursor-margins", cols: 10, rows: 6,
+		// 在滚动区域内 CUU/CUD 停在边距上，区域外可以越过
+		input: "\x1b[2;4r\x1b[3;1H\x1b[10Aa\x1b[10Bb\x1b[1;1H\x1b[10Bc\x1b[r\x1b[6;3H\x1b[10Ad",
+	},
+	{
+		name: "erase-line", cols: 10, rows: 4,
+		// EL 0/1/2 和 ECH，擦除的单元格使用当前背景色（BCE）
+		input: "0123456789\r\n0123456789\r\n0123456789\r\n0123456789" +
+			"\x1b[1;5H\x1b[K\x1b[2;5H\x1b[1K\x1b[3;5H\x1b[41m\x1b[2K\x1b[44m\x1b[4;3H\x1b[3X\x1b[m",
+	},
+	{
+		name: "erase-display", cols: 8, rows: 5,
+		// ED 0 擦除光标到屏幕末尾，ED 1 擦除屏幕开头到光标（含光标位置）
+		input: "abcdefgh\r\nabcdefgh\r\nabcdefgh\r\nabcdefgh\r\nabcdefgh" +
+			"\x1b[4;4H\x1b[J\x1b[2;3H\x1b[1J",
+	},
+	{
+		name: "erase-display-all", cols: 8, rows: 4,
+		// ED 2 清空整个屏幕，光标不动
+		input: "abc\r\ndef\x1b[2Jx",
+	},
+	{
+		name: "autowrap", cols: 8, rows: 4,
+		// 超出行宽自动换行，恰好写满一行时光标停在行尾，下一个字符才换行
+		input: "0123456789abc\r\nABCDEFGH\r\nxyz",
+	},
+	{
+		name: "wide-chars", cols: 9, rows: 5,
+		// 行尾放不下宽字符时换行，覆盖宽字符的一半会清除整个字符
+		input: "abcdefgh中文\r\n中文字\x1b[3;2Hx\x1b[4;1H中文字\x1b[4;3H\x1b[@\x1b[5;1H中文\x1b[5;2H\x1b[P",
+	},
+	{
+		name: "scroll-region", cols: 6, rows: 6,
+		// DECSTBM 区域内的 LF、RI、SU、SD，区域外的内容不动
+		input: "1\r\n2\r\n3\r\n4\r\n5\r\n6\x1b[2;5r\x1b[5;1H\nA\x1b[2;1H\x1bMB\x1b[S\x1b[2T",
+	},
+	{
+		name: "insert-delete-lines", cols: 6, rows: 6,
+		// IL/DL 只在滚动区域内移动行
+		input: "1\r\n2\r\n3\r\n4\r\n5\r\n6\x1b[2;5r\x1b[3;1H\x1b[2L\x1b[4;1H\x1b[M",
+	},
+	{
+		name: "insert-delete-chars", cols: 10, rows: 4,
+		// ICH、DCH、REP
+		input: "abcdefghij\x1b[1;3H\x1b[2@\r\nabcdefghij\x1b[2;3H\x1b[3P\r\nx\x1b[4b\r\n\x1b[31mz\x1b[3b",
+	},
+	{
+		name: "sgr", cols: 24, rows: 6,
+		input: "\x1b[1mbold\x1b[22m \x1b[2mdim\x1b[m \x1b[3mital\x1b[m \x1b[4mund\x1b[4:0m \x1b[5mblk\x1b[m\r\n" +
+			"\x1b[7mrev\x1b[m \x1b[8mhid\x1b[m \x1b[9mstr\x1b[m \x1b[31;42mred\x1b[39;49m \x1b[91mbri\x1b[m\r\n" +
+			"\x1b[38;5;208m256\x1b[m \x1b[38:5:33m256c\x1b[m \x1b[38;2;1;2;3mrgb\x1b[m \x1b[48:2::10:20:30mrgbc\x1b[m\r\n" +
+			"\x1b[1;4;31mmulti\x1b[0m plain \x1b[104mbg\x1b[49m",
+	},
+	{
+		name: "alt-screen", cols: 10, rows: 4,
+		// 1049 保存光标并切换到清空的备用屏幕，退出时恢复
+		input: "shell$ \x1b[?1049h\x1b[Hfull\x1b[2;2Hscreen\x1b[?1049lok",
+	},
+	{
+		name: "alt-screen-47", cols: 10, rows: 4,
+		// 47 切换时不清空备用屏幕，也不保存光标
+		input: "main\x1b[?47hALT\x1b[?47l\x1b[?47h\x1b[2;1H+",
+	},
+	{
+		name: "save-restore-cursor", cols: 12, rows: 4,
+		// DECSC/DECRC 同时保存画笔，CSI s/u 同样有效
+		input: "\x1b[2;3H\x1b[31m\x1b7\x1b[m\x1b[4;8Hx\x1b8r\x1b[1;1H\x1b[s\x1b[3;3Hy\x1b[uz",
+	},
+	{
+		name: "title", cols: 10, rows: 2,
+		// OSC 2 设置标题，CSI 22/23 t 压栈和出栈
+		input: "\x1b]2;first\x07\x1b[22;0t\x1b]0;second\x1b\\\x1b[23;0t",
+	},
+	{
+		name: "history", cols: 6, rows: 3,
+		// 滚出主屏幕的行进入历史
+		input: "1\r\n2\r\n3\r\n4\r\n5\r\n6",
+	},
+	{
+		name: "parser-robustness", cols: 16, rows: 4,
+		// CAN 中断序列、无效 UTF-8、未知序列、C1 控制字符、DCS 被忽略
+		input: "a\x1b[31\x18b\xff\xfec\x1b[?999zd\x1bP1$qm\x1b\\e\xc2\x85f\x1b[12;34;56;78;90;12;34;56;78;90;12;34;56;78;90;12;34;56;78;90;12;34;56;78;90;12;34;56;78;90;12;34;56;78;90;mg",
+	},
+	{
+		name: "meter-redraw", cols: 30, rows: 6,
+		// 仿照 htop 的画面：彩色进度条和反色选择条，第二帧只用 CUP、ECH、EL 局部重画
+		input: "\x1b[?1049h\x1b[H\x1b[2J" +
+			"  1[\x1b[32m||||\x1b[31m||\x1b[m\x1b[1;23H30.0%]" +
+			"\x1b[2;1HMem[\x1b[32m|||\x1b[34m||\x1b[33m|\x1b[m\x1b[2;22H1.2G/4G]" +
+			"\x1b[4;1H\x1b[30;42m  PID USER  CPU% Command\x1b[K\x1b[m" +
+			"\x1b[5;1H\x1b[30;46m    1 root   0.0 init\x1b[K\x1b[m" +
+			"\x1b[6;1H   42 user  12.5 bash" +
+			"\x1b[1;5H\x1b[32m||\x1b[31m|\x1b[m\x1b[3X\x1b[1;23H 9.5" +
+			"\x1b[5;1H    1 root   0.0 init\x1b[K" +
+			"\x1b[6;1H\x1b[30;46m   42 user  12.5 bash\x1b[K\x1b[6;13H 8.0\x1b[m",
+	},
+	{name: "bash", cols: 80, rows: 24, stream: "bash.raw"},
+	{name: "vim", cols: 80, rows: 24, stream: "vim.raw"},
+	{name: "less", cols: 80, rows: 24, stream: "less.raw"},
+	{name: "tmux", cols: 80, rows: 24, stream: "tmux.raw"},
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := []byte(tc.input)
+			if tc.stream != "" {
+				var err error
+				input, err = os.ReadFile(filepath.Join("testdata", "streams", tc.stream))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			e := NewEmulator(tc.cols, tc.rows)
+			e.Write(input)
+			got := dumpEmulator(e)
+
+			path := filepath.Join("testdata", "golden", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v（使用 -update 生成）", err)
+			}
+			if got != string(want) {
+				t.Errorf("%s 与期望输出不一致:\n%s", path, diffLines(string(want), got))
+			}
+		})
+	}
+}
+
+// dumpEmulator 将终端状态输出为文本：尺寸、光标、模式、标题、历史、屏幕内容和非默认样式
+func dumpEmulator(e *Emulator) string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "size: %dx%d\n", e.screenWidth, e.screenHeight)
+	fmt.Fprintf(&b, "cursor: %d,%d\n", e.cursorX, e.cursorY)
+	fmt.Fprintf(&b, "alt-screen: %v\n", e.altScreen)
+	fmt.Fprintf(&b, "title: %q\n", e.title)
+	fmt.Fprintf(&b, "history: %d\n", e.history.len())
+	for y := 0; y < e.history.len(); y++ {
+		// 历史行不补齐宽度，输出时补上空白以便和屏幕行对齐
+		line := e.history.at(y)
+		padding := strings.Repeat(" ", max(0, e.screenWidth-len(line.cells)))
+		fmt.Fprintf(&b, "  |%s%s|%s\n", rowText(line.cells), padding, wrapMark(line.wrapped))
+	}
+	b.WriteString("screen:\n")
+	for y, row := range e.screenBuffer {
+		fmt.Fprintf(&b, "  |%s|%s\n", rowText(row), wrapMark(e.wrapped[y]))
+	}
+	b.WriteString("styles:\n")
+	for y, row := range e.screenBuffer {
+		for _, run := range styleRuns(row) {
+			fmt.Fprintf(&b, "  %d:%s\n", y, run)
+		}
+	}
+	return b.String()
+}
+
+// rowText 返回一行的文本，宽字符的占位符不输出，使每行的显示宽度与列数一致
+func rowText(row []Cell) string {
+	var line strings.Builder
+	for _, cell := range row {
+		switch {
+		case cell.Width == 0:
+		case cell.Char == "":
+			line.WriteByte(' ')
+		default:
+			line.WriteString(cell.Char)
+		}
+	}
+	return line.String()
+}
+
+func wrapMark(wrapped bool) string {
+	if wrapped {
+		return " wrapped"
+	}
+	return ""
+}
+
+// styleRuns 将一行中样式相同的连续非默认单元格合并输出，如 "3-5 fg=1 bold"
+func styleRuns(row []Cell) []string {
+	var runs []string
+	for start := 0; start < len(row); {
+		end := start
+		for end+1 < len(row) && row[end+1].Style == row[start].Style {
+			end++
+		}
+		if style := row[start].Style; style != (Style{}) {
+			runs = append(runs, fmt.Sprintf("%d-%d %s", start, end, formatStyle(style)))
+		}
+		start = end + 1
+	}
+	return runs
+}
+
+var attrNames = []struct {
+	attr Attr
+	name string
+}{
+	{AttrBold, "bold"}, {AttrDim, "dim"}, {AttrItalic, "italic"}, {AttrUnderline, "underline"},
+	{AttrBlink, "blink"}, {AttrReverse, "reverse"}, {AttrHidden, "hidden"}, {AttrStrike, "strike"},
+}
+
+func formatStyle(s Style) string {
+	var parts []string
+	if s.Fg != (Color{}) {
+		parts = append(parts, "fg="+formatColor(s.Fg))
+	}
+	if s.Bg != (Color{}) {
+		parts = append(parts, "bg="+formatColor(s.Bg))
+	}
+	for _, a := range attrNames {
+		if s.Attr&a.attr != 0 {
+			parts = append(parts, a.name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatColor(c Color) string {
+	switch c.kind {
+	case colorIndexed:
+		return fmt.Sprint(c.index)
+	case colorRGB:
+		return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+	}
+	return "default"
+}
+
+// diffLines 列出期望和实际输出中不同的行
+func diffLines(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "line %d:\n  want %q\n  got  %q\n", i+1, w, g)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package vt
+
+import "strings"
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// EncodePaste 将粘贴的文本转换为写入 pty 的内容：换行统一转换为回车（与按下回车键一致），
+// 括号粘贴模式下用 ESC [200~ 和 ESC [201~ 包围，并去掉文本中的起止标记，
+// 避免粘贴的内容提前结束粘贴并被当作按键执行
+func EncodePaste(text string, bracketed bool) string {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	if !bracketed {
+		return text
+	}
+	// 去掉一个标记后可能拼出新的标记，反复替换直到不再出现
+	for strings.Contains(text, pasteStart) || strings.Contains(text, pasteEnd) {
+		text = strings.ReplaceAll(text, pasteStart, "")
+		text = strings.ReplaceAll(text, pasteEnd, "")
+	}
+	return pasteStart + text + pasteEnd
+}
@@ -0,0 +1,189 @@
+package vt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEncodePaste(t *testing.T) {
+	tests := []struct {
+		text      string
+		bracketed bool
+		want      string
+	}{
+		{"ls\npwd\r\n", false, "ls\rpwd\r"},
+		{"ls\npwd", true, "\x1b[200~ls\rpwd\x1b[201~"},
+		// 文本中的结束标记被去掉，即使去掉后又拼出新的标记
+		{"a\x1b[201~rm -rf ~\n", true, "\x1b[200~arm -rf ~\r\x1b[201~"},
+		{"\x1b[20\x1b[201~1~x", true, "\x1b[200~x\x1b[201~"},
+	}
+	for _, tt := range tests {
+		if got := EncodePaste(tt.text, tt.bracketed); got != tt.want {
+			t.Errorf("EncodePaste(%q, %v) = %q, want %q", tt.text, tt.bracketed, got, tt.want)
+		}
+	}
+}
+
+func TestInputModes(t *testing.T) {
+	e := NewEmulator(10, 2)
+	fmt.Fprint(e, "\x1b[?2004h\x1b[?1h\x1b=")
+	want := Modes{BracketedPaste: true, AppCursorKeys: true, AppKeypad: true}
+	if got := e.Modes(); got != want {
+		t.Fatalf("modes = %+v, want %+v", got, want)
+	}
+	fmt.Fprint(e, "\x1b[?2004l\x1b[?1l\x1b>")
+	if got := e.Modes(); got != (Modes{}) {
+		t.Fatalf("modes after reset = %+v, want all off", got)
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	legacy := Modes{}
+	tests := []struct {
+		name  string
+		ev    KeyEvent
+		modes Modes
+		want  string
+	}{
+		{"enter", KeyEvent{Key: KeyEnter}, legacy, "\r"},
+		{"backspace", KeyEvent{Key: KeyBackspace}, legacy, "\x7f"},
+		{"shift tab", KeyEvent{Key: KeyTab, Mod: ModShift}, legacy, "\x1b[Z"},
+		{"up", KeyEvent{Key: KeyUp}, legacy, "\x1b[A"},
+		{"up app cursor", KeyEvent{Key: KeyUp}, Modes{AppCursorKeys: true}, "\x1bOA"},
+		{"ctrl up", KeyEvent{Key: KeyUp, Mod: ModCtrl}, Modes{AppCursorKeys: true}, "\x1b[1;5A"},
+		{"shift alt f5", KeyEvent{Key: KeyF5, Mod: ModShift | ModAlt}, legacy, "\x1b[15;4~"},
+		{"ctrl f1", KeyEvent{Key: KeyF1, Mod: ModCtrl}, legacy, "\x1b[1;5P"},
+		{"keypad app", KeyEvent{Key: KeyKP5}, Modes{AppKeypad: true}, "\x1bOu"},
+		{"keypad", KeyEvent{Key: KeyKP5}, legacy, "5"},
+		{"text", KeyEvent{Code: '1', Text: "!", Mod: ModShift}, legacy, "!"},
+		{"ctrl l", KeyEvent{Code: 'l', Text: "l", Mod: ModCtrl}, legacy, "\x0c"},
+		{"ctrl [", KeyEvent{Code: '[', Text: "[", Mod: ModCtrl}, legacy, "\x1b"},
+		{"ctrl space", KeyEvent{Code: ' ', Text: " ", Mod: ModCtrl}, legacy, "\x00"},
+		{"alt b", KeyEvent{Code: 'b', Text: "b", Mod: ModAlt}, legacy, "\x1bb"},
+		{"ctrl alt c", KeyEvent{Code: 'c', Text: "c", Mod: ModCtrl | ModAlt}, legacy, "\x1b\x03"},
+		// modifyOtherKeys 1 级只编码没有控制字符的组合
+		{"mok1 ctrl a", KeyEvent{Code: 'a', Text: "a", Mod: ModCtrl}, Modes{ModifyOtherKeys: 1}, "\x01"},
+		{"mok1 ctrl ;", KeyEvent{Code: ';', Text: ";", Mod: ModCtrl}, Modes{ModifyOtherKeys: 1}, "\x1b[27;5;59~"},
+		{"mok2 ctrl a", KeyEvent{Code: 'a', Text: "a", Mod: ModCtrl}, Modes{ModifyOtherKeys: 2}, "\x1b[27;5;97~"},
+		{"mok2 ctrl shift a", KeyEvent{Code: 'a', Text: "A", Mod: ModCtrl | ModShift}, Modes{ModifyOtherKeys: 2}, "\x1b[27;6;65~"},
+		{"mok2 plain", KeyEvent{Code: 'a', Text: "a"}, Modes{ModifyOtherKeys: 2}, "a"},
+		// kitty 协议
+		{"kitty text", KeyEvent{Code: 'a', Text: "a"}, Modes{KittyKeyboard: 1}, "a"},
+		{"kitty ctrl a", KeyEvent{Code: 'a', Text: "a", Mod: ModCtrl}, Modes{KittyKeyboard: 1}, "\x1b[97;5u"},
+		{"kitty ctrl shift a", KeyEvent{Code: 'a', Text: "A", Mod: ModCtrl | ModShift}, Modes{KittyKeyboard: 1}, "\x1b[97;6u"},
+		{"kitty esc", KeyEvent{Key: KeyEscape}, Modes{KittyKeyboard: 1}, "\x1b[27u"},
+		{"kitty enter", KeyEvent{Key: KeyEnter}, Modes{KittyKeyboard: 1}, "\r"},
+		{"kitty ctrl enter", KeyEvent{Key: KeyEnter, Mod: ModCtrl}, Modes{KittyKeyboard: 1}, "\x1b[13;5u"},
+		{"kitty up", KeyEvent{Key: KeyUp}, Modes{KittyKeyboard: 1}, "\x1b[A"},
+		{"kitty f3", KeyEvent{Key: KeyF3, Mod: ModShift}, Modes{KittyKeyboard: 1}, "\x1b[13;2~"},
+		{"kitty keypad", KeyEvent{Key: KeyKP0}, Modes{KittyKeyboard: 1}, "\x1b[57399u"},
+		{"kitty all keys", KeyEvent{Code: 'a', Text: "a"}, Modes{KittyKeyboard: 8}, "\x1b[97u"},
+		{"kitty all keys up", KeyEvent{Key: KeyUp}, Modes{KittyKeyboard: 8}, "\x1b[A"},
+		{"kitty text flag", KeyEvent{Code: 'a', Text: "A", Mod: ModShift}, Modes{KittyKeyboard: 8 | 16}, "\x1b[97;2;65u"},
+	}
+	for _, tt := range tests {
+		if got := EncodeKey(tt.ev, tt.modes); got != tt.want {
+			t.Errorf("%s: EncodeKey(%+v) = %q, want %q", tt.name, tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestKeyboardProtocols(t *testing.T) {
+	e := NewEmulator(10, 2)
+	fmt.Fprint(e, "\x1b[>4;2m\x1b[>1u\x1b[>9u")
+	if got := e.Modes(); got.ModifyOtherKeys != 2 || got.KittyKeyboard != 9 {
+		t.Fatalf("modes = %+v, want modifyOtherKeys 2, kitty flags 9", got)
+	}
+	fmt.Fprint(e, "\x1b[?u")
+	if got, want := string(e.Replies()), "\x1b[?9u"; got != want {
+		t.Fatalf("replies = %q, want %q", got, want)
+	}
+	if got := e.Replies(); len(got) != 0 {
+		t.Fatalf("replies not cleared: %q", got)
+	}
+
+	// 备用屏幕有独立的标志栈
+	fmt.Fprint(e, "\x1b[?1049h")
+	if got := e.Modes().KittyKeyboard; got != 0 {
+		t.Fatalf("alt screen kitty flags = %d, want 0", got)
+	}
+	fmt.Fprint(e, "\x1b[=16;2u\x1b[?1049l")
+	if got := e.Modes().KittyKeyboard; got != 9 {
+		t.Fatalf("kitty flags after alt screen = %d, want 9", got)
+	}
+
+	fmt.Fprint(e, "\x1b[=8;3u")
+	if got := e.Modes().KittyKeyboard; got != 1 {
+		t.Fatalf("kitty flags after clear = %d, want 1", got)
+	}
+	fmt.Fprint(e, "\x1b[<u")
+	if got := e.Modes().KittyKeyboard; got != 1 {
+		t.Fatalf("kitty flags after pop = %d, want 1", got)
+	}
+	fmt.Fprint(e, "\x1b[<5u\x1b[>4m")
+	if got := e.Modes(); got != (Modes{}) {
+		t.Fatalf("modes after reset = %+v, want all off", got)
+	}
+
+	// 不带参数时同样关闭 modifyOtherKeys
+	fmt.Fprint(e, "\x1b[>4;1m\x1b[>m")
+	if got := e.Modes().ModifyOtherKeys; got != 0 {
+		t.Fatalf("modifyOtherKeys after CSI > m = %d, want 0", got)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	// 输入法的预编辑文本按终端中的列数绘制
+	if got := StringWidth("ni你好"); got != 6 {
+		t.Fatalf("StringWidth = %d, want 6", got)
+	}
+}
+
+func TestEncodeMouse(t *testing.T) {
+	normal := Modes{MouseTracking: MouseTrackingNormal}
+	sgr := Modes{MouseTracking: MouseTrackingButton, MouseSGR: true}
+	tests := []struct {
+		name  string
+		ev    MouseEvent
+		modes Modes
+		want  string
+	}{
+		{"off", MouseEvent{Action: MousePress}, Modes{}, ""},
+		{"press", MouseEvent{Action: MousePress, Button: MouseLeft, X: 2, Y: 3}, normal, "\x1b[M #$"},
+		{"release", MouseEvent{Action: MouseRelease, Button: MouseRight, X: 2, Y: 3}, normal, "\x1b[M##$"},
+		{"ctrl press", MouseEvent{Action: MousePress, Button: MouseMiddle, Mod: ModCtrl}, normal, "\x1b[M1!!"},
+		{"wheel", MouseEvent{Action: MousePress, Button: MouseWheelDown}, normal, "\x1b[Ma!!"},
+		{"normal motion", MouseEvent{Action: MouseMotion, Button: MouseLeft}, normal, ""},
+		{"x10 release", MouseEvent{Action: MouseRelease, Button: MouseLeft}, Modes{MouseTracking: MouseTrackingX10}, ""},
+		{"x10 no mods", MouseEvent{Action: MousePress, Button: MouseLeft, Mod: ModCtrl}, Modes{MouseTracking: MouseTrackingX10}, "\x1b[M !!"},
+		{"legacy out of range", MouseEvent{Action: MousePress, X: 300}, normal, ""},
+		{"sgr press", MouseEvent{Action: MousePress, Button: MouseLeft, X: 300, Y: 4}, sgr, "\x1b[<0;301;5M"},
+		{"sgr release", MouseEvent{Action: MouseRelease, Button: MouseRight, X: 1, Y: 1}, sgr, "\x1b[<2;2;2m"},
+		{"sgr drag", MouseEvent{Action: MouseMotion, Button: MouseLeft, Mod: ModAlt}, sgr, "\x1b[<40;1;1M"},
+		{"button mode hover", MouseEvent{Action: MouseMotion, Button: MouseNoButton}, sgr, ""},
+		{"any mode hover", MouseEvent{Action: MouseMotion, Button: MouseNoButton}, Modes{MouseTracking: MouseTrackingAny, MouseSGR: true}, "\x1b[<35;1;1M"},
+		{"sgr wheel release", MouseEvent{Action: MouseRelease, Button: MouseWheelUp}, sgr, ""},
+	}
+	for _, tt := range tests {
+		if got := EncodeMouse(tt.ev, tt.modes); got != tt.want {
+			t.Errorf("%s: EncodeMouse(%+v) = %q, want %q", tt.name, tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestMouseModes(t *testing.T) {
+	e := NewEmulator(10, 2)
+	fmt.Fprint(e, "\x1b[?1000h\x1b[?1002h\x1b[?1006h")
+	if got := e.Modes(); got.MouseTracking != MouseTrackingButton || !got.MouseSGR {
+		t.Fatalf("modes = %+v, want button tracking with SGR", got)
+	}
+	// 关闭的不是当前模式时保持不变
+	fmt.Fprint(e, "\x1b[?1000l")
+	if got := e.Modes().MouseTracking; got != MouseTrackingButton {
+		t.Fatalf("tracking after resetting 1000 = %d, want button", got)
+	}
+	fmt.Fprint(e, "\x1b[?1002l\x1b[?1006l")
+	if got := e.Modes(); got != (Modes{}) {
+		t.Fatalf("modes after reset = %+v, want all off", got)
+	}
+}
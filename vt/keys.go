@@ -0,0 +1,383 @@
+package vt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Key 与界面库无关的功能键，文字键使用 KeyEvent.Code
+type Key int
+
+const (
+	KeyNone Key = iota
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyKP0
+	KeyKP1
+	KeyKP2
+	KeyKP3
+	KeyKP4
+	KeyKP5
+	KeyKP6
+	KeyKP7
+	KeyKP8
+	KeyKP9
+	KeyKPDecimal
+	KeyKPDivide
+	KeyKPMultiply
+	KeyKPMinus
+	KeyKPPlus
+	KeyKPEnter
+)
+
+// Mod 按键的修饰键
+type Mod int
+
+const (
+	ModShift Mod = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// KeyEvent 一次按键
+// 功能键设置 Key；文字键设置 Code（不带 Shift 时的字符，如 Shift+1 为 '1'）和 Text（实际输入的文字，如 "!"）
+type KeyEvent struct {
+	Key  Key
+	Code rune
+	Text string
+	Mod  Mod
+}
+
+// kitty 键盘协议的标志位
+const (
+	kittyDisambiguate = 1  // 有歧义的按键（Esc、Alt/Ctrl 组合键）使用 CSI u
+	kittyAllKeys      = 8  // 所有按键都使用转义序列
+	kittyText         = 16 // 附带按键输入的文字
+	kittyAllFlags     = 31 // 协议定义的所有标志位，其余的位被忽略
+)
+
+// maxKittyStack kitty 键盘协议标志栈的最大深度，超出时丢弃最老的一项
+const maxKittyStack = 16
+
+// functionKey 功能键的 CSI 编码：number 为参数，final 为结束字符，如 Delete 为 3~，Up 为 1A
+type functionKey struct {
+	number int
+	final  byte
+}
+
+var functionKeys = map[Key]functionKey{
+	KeyUp: {1, 'A'}, KeyDown: {1, 'B'}, KeyRight: {1, 'C'}, KeyLeft: {1, 'D'},
+	KeyHome: {1, 'H'}, KeyEnd: {1, 'F'},
+	KeyInsert: {2, '~'}, KeyDelete: {3, '~'}, KeyPageUp: {5, '~'}, KeyPageDown: {6, '~'},
+	KeyF1: {1, 'P'}, KeyF2: {1, 'Q'}, KeyF3: {1, 'R'}, KeyF4: {1, 'S'},
+	KeyF5: {15, '~'}, KeyF6: {17, '~'}, KeyF7: {18, '~'}, KeyF8: {19, '~'},
+	KeyF9: {20, '~'}, KeyF10: {21, '~'}, KeyF11: {23, '~'}, KeyF12: {24, '~'},
+}
+
+// keypadKeys 小键盘按键：数字模式下的文字、应用模式 (DECKPAM) 下 ESC O 之后的字符、kitty 协议中的编码
+var keypadKeys = map[Key]struct {
+	text  string
+	app   byte
+	kitty int
+}{
+	KeyKP0: {"0", 'p', 57399}, KeyKP1: {"1", 'q', 57400}, KeyKP2: {"2", 'r', 57401},
+	KeyKP3: {"3", 's', 57402}, KeyKP4: {"4", 't', 57403}, KeyKP5: {"5", 'u', 57404},
+	KeyKP6: {"6", 'v', 57405}, KeyKP7: {"7", 'w', 57406}, KeyKP8: {"8", 'x', 57407},
+	KeyKP9: {"9", 'y', 57408}, KeyKPDecimal: {".", 'n', 57409}, KeyKPDivide: {"/", 'o', 57410},
+	KeyKPMultiply: {"*", 'j', 57411}, KeyKPMinus: {"-", 'm', 57412}, KeyKPPlus: {"+", 'k', 57413},
+	KeyKPEnter: {"\r", 'M', 57414},
+}
+
+// modifierParam 修饰键在 CSI 序列中的参数：1 + Shift(1) + Alt(2) + Ctrl(4)
+func modifierParam(mod Mod) int {
+	return 1 + int(mod&(ModShift|ModAlt|ModCtrl))
+}
+
+// EncodeKey 按终端当前的模式将按键编码为写入 pty 的内容，无法编码时返回空字符串
+// 依次支持：传统编码（Ctrl 组合为 C0 控制字符，Alt 加 ESC 前缀，修饰的功能键为 CSI 1;5A 形式）、
+// DECCKM/DECKPAM、xterm modifyOtherKeys 以及 kitty 键盘协议
+func EncodeKey(ev KeyEvent, modes Modes) string {
+	if modes.KittyKeyboard&(kittyDisambiguate|kittyAllKeys) != 0 {
+		if s, ok := encodeKitty(ev, modes); ok {
+			return s
+		}
+	}
+	if ev.Key != KeyNone {
+		return encodeSpecialKey(ev, modes)
+	}
+	return encodeTextKey(ev, modes)
+}
+
+// encodeSpecialKey 传统编码的功能键、编辑键和小键盘
+func encodeSpecialKey(ev KeyEvent, modes Modes) string {
+	mod := ev.Mod
+	alt := ""
+	if mod&ModAlt != 0 {
+		alt = "\x1b"
+	}
+	switch ev.Key {
+	case KeyEnter:
+		return alt + "\r"
+	case KeyTab:
+		if mod&ModShift != 0 {
+			return alt + "\x1b[Z"
+		}
+		return alt + "\t"
+	case KeyBackspace:
+		// Ctrl+Backspace 发送 ^H，用于和 Backspace（DEL）区分
+		if mod&ModCtrl != 0 {
+			return alt + "\b"
+		}
+		return alt + "\x7f"
+	case KeyEscape:
+		return alt + "\x1b"
+	}
+
+	if kp, ok := keypadKeys[ev.Key]; ok {
+		if modes.AppKeypad && mod == 0 {
+			return "\x1bO" + string(kp.app)
+		}
+		return alt + kp.text
+	}
+
+	fk, ok := functionKeys[ev.Key]
+	if !ok {
+		return ""
+	}
+	if mod != 0 {
+		return fmt.Sprintf("\x1b[%d;%d%c", fk.number, modifierParam(mod), fk.final)
+	}
+	switch {
+	case fk.final == '~':
+		return fmt.Sprintf("\x1b[%d~", fk.number)
+	case fk.final >= 'P' && fk.final <= 'S':
+		// F1-F4 不带修饰键时使用 SS3
+		return "\x1bO" + string(fk.final)
+	case modes.AppCursorKeys:
+		return "\x1bO" + string(fk.final)
+	}
+	return "\x1b[" + string(fk.final)
+}
+
+// encodeTextKey 传统编码的文字键
+func encodeTextKey(ev KeyEvent, modes Modes) string {
+	text := ev.Text
+	if text == "" && ev.Code != 0 {
+		text = string(ev.Code)
+	}
+	mod := ev.Mod
+	if mod&(ModCtrl|ModAlt) == 0 {
+		return text
+	}
+
+	// modifyOtherKeys：2 级时所有带修饰键的文字键，1 级时无法用控制字符表示的 Ctrl 组合，
+	// 编码为 CSI 27;修饰;字符 ~，字符为按下 Shift 后的字符
+	ctrl, hasCtrl := ctrlCode(ev.Code)
+	if modes.ModifyOtherKeys >= 2 || modes.ModifyOtherKeys == 1 && mod&ModCtrl != 0 && !hasCtrl {
+		code := ev.Code
+		if r := []rune(text); len(r) == 1 {
+			code = r[0]
+		}
+		return fmt.Sprintf("\x1b[27;%d;%d~", modifierParam(mod), code)
+	}
+
+	// 没有对应的控制字符时按未修饰的文字发送
+	if mod&ModCtrl != 0 && hasCtrl {
+		text = ctrl
+	}
+	if mod&ModAlt != 0 {
+		text = "\x1b" + text
+	}
+	return text
+}
+
+// ctrlCode 返回 Ctrl 加字符对应的 C0 控制字符，与 xterm 的传统编码一致
+func ctrlCode(code rune) (string, bool) {
+	switch {
+	case code >= 'a' && code <= 'z':
+		return string(code - 'a' + 1), true
+	case code >= '@' && code <= '_':
+		// @ [ \ ] ^ _ 以及大写字母
+		return string(code - '@'), true
+	case code == ' ' || code == '2' || code == '`':
+		return "\x00", true
+	case code >= '3' && code <= '7':
+		// Ctrl+3 到 Ctrl+7 对应 ESC、FS、GS、RS、US
+		return string(code - '3' + 0x1b), true
+	case code == '8' || code == '?':
+		return "\x7f", true
+	case code == '/' || code == '-':
+		return "\x1f", true
+	}
+	return "", false
+}
+
+// encodeKitty kitty 键盘协议的编码，返回 false 时使用传统编码
+func encodeKitty(ev KeyEvent, modes Modes) (string, bool) {
+	flags := modes.KittyKeyboard
+	mod := ev.Mod
+	allKeys := flags&kittyAllKeys != 0
+
+	var code int
+	final := byte('u')
+	switch {
+	case ev.Key == KeyEnter:
+		code = 13
+	case ev.Key == KeyTab:
+		code = 9
+	case ev.Key == KeyBackspace:
+		code = 127
+	case ev.Key == KeyEscape:
+		// 有歧义模式下 Esc 总是编码，避免和转义序列的开头混淆
+		code = 27
+		allKeys = true
+	case ev.Key != KeyNone:
+		// 小键盘在有歧义模式下也单独编码，与主键盘区分
+		if kp, ok := keypadKeys[ev.Key]; ok {
+			code = kp.kitty
+			break
+		}
+		fk, ok := functionKeys[ev.Key]
+		if !ok {
+			return "", false
+		}
+		// F3 的 CSI R 与光标位置报告冲突，kitty 协议中使用 13~
+		if ev.Key == KeyF3 {
+			fk = functionKey{13, '~'}
+		}
+		code, final = fk.number, fk.final
+		if !allKeys && mod == 0 {
+			// 不带修饰键的功能键与传统编码相同
+			return "", false
+		}
+	default:
+		code = int(unicode.ToLower(ev.Code))
+		if code == 0 {
+			return "", false
+		}
+		// 只带 Shift 的文字键（以及不带修饰键的）直接输入文字，除非要求所有按键都编码
+		if !allKeys && mod&(ModCtrl|ModAlt) == 0 {
+			return "", false
+		}
+	}
+	// Enter/Tab/Backspace 不带修饰键时只有在要求所有按键都编码时才编码
+	if code == 13 || code == 9 || code == 127 {
+		if !allKeys && mod == 0 {
+			return "", false
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[")
+	// 不带修饰键的方向键等省略参数 1，如 CSI A
+	if code != 1 || mod != 0 {
+		b.WriteString(strconv.Itoa(code))
+	}
+	text := ""
+	if flags&kittyText != 0 && allKeys && ev.Key == KeyNone && mod&^ModShift == 0 {
+		for i, r := range ev.Text {
+			if i > 0 {
+				text += ":"
+			}
+			text += strconv.Itoa(int(r))
+		}
+	}
+	if mod != 0 || text != "" {
+		b.WriteByte(';')
+		if mod != 0 {
+			b.WriteString(strconv.Itoa(modifierParam(mod)))
+		}
+	}
+	if text != "" {
+		b.WriteByte(';')
+		b.WriteString(text)
+	}
+	b.WriteByte(final)
+	return b.String(), true
+}
+
+// kittyStack 返回当前屏幕的 kitty 键盘协议标志栈，主屏幕和备用屏幕各自独立
+func (e *Emulator) kittyStack() *[]int {
+	if e.altScreen {
+		return &e.altKitty
+	}
+	return &e.primaryKitty
+}
+
+// kittyFlags 返回当前屏幕生效的 kitty 键盘协议标志
+func (e *Emulator) kittyFlags() int {
+	stack := *e.kittyStack()
+	if len(stack) == 0 {
+		return 0
+	}
+	return stack[len(stack)-1]
+}
+
+// kittyKeyboard 处理 kitty 键盘协议的控制序列：
+// CSI > flags u 压栈，CSI < n u 出栈 n 项，CSI = flags ; mode u 修改栈顶（1 设置，2 置位，3 清除），CSI ? u 查询
+func (e *Emulator) kittyKeyboard(marker byte, params Params) {
+	stack := e.kittyStack()
+	switch marker {
+	case '>':
+		if len(*stack) >= maxKittyStack {
+			*stack = (*stack)[1:]
+		}
+		*stack = append(*stack, params.Get(0, 0)&kittyAllFlags)
+	case '<':
+		n := min(len(*stack), params.Get(0, 1))
+		*stack = (*stack)[:len(*stack)-n]
+	case '=':
+		if len(*stack) == 0 {
+			*stack = append(*stack, 0)
+		}
+		top := &(*stack)[len(*stack)-1]
+		flags := params.Get(0, 0) & kittyAllFlags
+		switch params.Get(1, 1) {
+		case 1:
+			*top = flags
+		case 2:
+			*top |= flags
+		case 3:
+			*top &^= flags
+		}
+	case '?':
+		e.reply(fmt.Sprintf("\x1b[?%du", e.kittyFlags()))
+	}
+}
+
+// setModifyOtherKeys 处理 CSI > 4 ; n m，只支持 modifyOtherKeys，不带级别时关闭
+// 不带参数的 CSI > m 恢复所有修饰键设置的默认值，同样关闭
+func (e *Emulator) setModifyOtherKeys(params Params) {
+	switch params.Get(0, 0) {
+	case 0:
+		e.modifyOtherKeys = 0
+	case 4:
+		e.modifyOtherKeys = min(2, params.Get(1, 0))
+	}
+}
@@ -0,0 +1,127 @@
+package vt
+
+import "fmt"
+
+// MouseTracking 程序请求的鼠标跟踪模式 (DECSET 9/1000/1002/1003)，决定哪些鼠标事件需要报告
+type MouseTracking int
+
+const (
+	MouseTrackingNone   MouseTracking = iota
+	MouseTrackingX10                  // 只报告按下 (DECSET 9)
+	MouseTrackingNormal               // 报告按下和松开 (DECSET 1000)
+	MouseTrackingButton               // 另外报告按住按键时的移动 (DECSET 1002)
+	MouseTrackingAny                  // 另外报告所有移动 (DECSET 1003)
+)
+
+// MouseButton 鼠标按键，滚轮的每一格作为一次按下
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNoButton // 没有按键的移动
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+// MouseAction 鼠标事件的类型
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+// MouseEvent 一次鼠标事件，X/Y 为屏幕上的单元格坐标（从0开始）
+type MouseEvent struct {
+	Action MouseAction
+	Button MouseButton
+	X, Y   int
+	Mod    Mod
+}
+
+// legacyMouseLimit 传统编码中坐标加 33 后必须能放进一个字节，超出的位置无法报告
+const legacyMouseLimit = 255 - 32
+
+// code 按键在报告中的编号：左中右为 0-2，滚轮从 64 开始
+func (b MouseButton) code() int {
+	switch {
+	case b >= MouseWheelUp:
+		return 64 + int(b-MouseWheelUp)
+	case b == MouseNoButton:
+		return 3
+	}
+	return int(b)
+}
+
+// EncodeMouse 按程序请求的跟踪模式和编码将鼠标事件编码为写入 pty 的内容，模式不需要报告时返回空字符串
+// 传统编码为 ESC [ M 加三个字节，SGR 编码 (DECSET 1006) 为 ESC [ < 按键 ; 列 ; 行 M，松开时以 m 结尾
+func EncodeMouse(ev MouseEvent, modes Modes) string {
+	wheel := ev.Button >= MouseWheelUp
+	switch modes.MouseTracking {
+	case MouseTrackingNone:
+		return ""
+	case MouseTrackingX10:
+		if ev.Action != MousePress || wheel {
+			return ""
+		}
+	case MouseTrackingNormal:
+		if ev.Action == MouseMotion {
+			return ""
+		}
+	case MouseTrackingButton:
+		if ev.Action == MouseMotion && ev.Button == MouseNoButton {
+			return ""
+		}
+	}
+	// 滚轮没有松开事件
+	if wheel && ev.Action == MouseRelease {
+		return ""
+	}
+
+	code := ev.Button.code()
+	// X10 模式不报告修饰键
+	if modes.MouseTracking != MouseTrackingX10 {
+		if ev.Mod&ModShift != 0 {
+			code += 4
+		}
+		if ev.Mod&ModAlt != 0 {
+			code += 8
+		}
+		if ev.Mod&ModCtrl != 0 {
+			code += 16
+		}
+	}
+	if ev.Action == MouseMotion {
+		code += 32
+	}
+
+	if modes.MouseSGR {
+		final := 'M'
+		if ev.Action == MouseRelease {
+			final = 'm'
+		}
+		return fmt.Sprintf("\x1b[<%d;%d;%d%c", code, ev.X+1, ev.Y+1, final)
+	}
+	// 传统编码的松开事件不区分按键
+	if ev.Action == MouseRelease {
+		code = code&^3 | 3
+	}
+	if ev.X >= legacyMouseLimit || ev.Y >= legacyMouseLimit {
+		return ""
+	}
+	return string([]byte{0x1b, '[', 'M', byte(32 + code), byte(33 + ev.X), byte(33 + ev.Y)})
+}
+
+// setMouseTracking 开启或关闭鼠标跟踪模式，关闭的不是当前模式时保持不变
+func (e *Emulator) setMouseTracking(mode MouseTracking, enable bool) {
+	if enable {
+		e.mouseTracking = mode
+	} else if e.mouseTracking == mode {
+		e.mouseTracking = MouseTrackingNone
+	}
+}
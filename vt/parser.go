@@ -0,0 +1,409 @@
+package vt
+
+import "unicode/utf8"
+
+// 解析器状态，参考 Paul Williams 的 DEC ANSI 兼容解析器
+// https://vt100.net/emu/dec_ansi_parser
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCsiEntry
+	stateCsiParam
+	stateCsiIntermediate
+	stateCsiIgnore
+	stateDcsEntry
+	stateDcsParam
+	stateDcsIntermediate
+	stateDcsPassthrough
+	stateDcsIgnore
+	stateOscString
+	stateSosPmApcString
+)
+
+const (
+	maxParams        = 32      // 参数个数上限，超出的参数被丢弃
+	maxValues        = 256     // 参数和子参数的总个数上限，超出的部分被丢弃
+	maxParamValue    = 65535   // 单个参数的最大值
+	maxIntermediates = 2       // 中间字节个数上限
+	maxOscLength     = 1 << 16 // OSC 字符串长度上限
+)
+
+// Params CSI/DCS 序列的参数，每个参数可以带有以冒号分隔的子参数（如 38:2::r:g:b）
+type Params [][]int
+
+// Get 返回第i个参数的值，参数缺省或为0时返回def
+func (p Params) Get(i, def int) int {
+	if i >= len(p) || len(p[i]) == 0 || p[i][0] == 0 {
+		return def
+	}
+	return p[i][0]
+}
+
+// ParserHandler 接收解析器分发的动作
+type ParserHandler interface {
+	// Print 输出一个可显示字符
+	Print(r rune)
+	// Execute 执行 C0 或 C1 控制字符
+	Execute(b byte)
+	// CsiDispatch 分发一个完整的 CSI 序列，私有标记（< = > ?）作为第一个中间字节传入
+	CsiDispatch(params Params, intermediates []byte, final byte)
+	// EscDispatch 分发一个完整的 ESC 序列
+	EscDispatch(intermediates []byte, final byte)
+	// OscDispatch 分发一个 OSC 字符串，按分号切分，bellTerminated 表示以 BEL 结束
+	OscDispatch(params [][]byte, bellTerminated bool)
+	// DcsHook 进入 DCS 透传，之后的数据通过 DcsPut 传入，以 DcsUnhook 结束
+	DcsHook(params Params, intermediates []byte, final byte)
+	DcsPut(r rune)
+	DcsUnhook()
+}
+
+// Parser VT500 风格的转义序列解析器
+// 输入按 UTF-8 解码为字符后驱动状态机，U+0080-U+009F 作为 C1 控制字符处理
+type Parser struct {
+	handler ParserHandler
+	state   parserState
+
+	// UTF-8 解码缓冲
+	utf8Buf  [utf8.UTFMax]byte
+	utf8Len  int
+	utf8Need int
+
+	// 参数：values 按顺序保存所有参数和子参数，starts 记录每个参数在 values 中的起始位置
+	values    []int
+	starts    []int
+	current   int
+	overflow  bool
+	intermeds []byte
+	params    Params
+
+	osc []byte
+}
+
+// NewParser 创建一个解析器，解析出的动作分发给 handler
+func NewParser(handler ParserHandler) *Parser {
+	p := &Parser{
+		handler:   handler,
+		values:    make([]int, 0, maxValues),
+		starts:    make([]int, 0, maxParams),
+		intermeds: make([]byte, 0, maxIntermediates+1),
+		params:    make(Params, 0, maxParams),
+	}
+	p.clear()
+	return p
+}
+
+// Feed 依次解析一段字节
+func (p *Parser) Feed(data []byte) {
+	for _, b := range data {
+		p.Advance(b)
+	}
+}
+
+// Advance 解析一个字节
+func (p *Parser) Advance(b byte) {
+	if p.utf8Need == 0 {
+		switch {
+		case b < 0x80:
+			p.advanceRune(rune(b))
+		case b&0xe0 == 0xc0:
+			p.startUTF8(b, 2)
+		case b&0xf0 == 0xe0:
+			p.startUTF8(b, 3)
+		case b&0xf8 == 0xf0:
+			p.startUTF8(b, 4)
+		default:
+			p.advanceRune(utf8.RuneError)
+		}
+		return
+	}
+
+	// 期望续字节却收到其他字节：丢弃不完整的字符后重新处理该字节
+	if b&0xc0 != 0x80 {
+		p.utf8Need = 0
+		p.advanceRune(utf8.RuneError)
+		p.Advance(b)
+		return
+	}
+	p.utf8Buf[p.utf8Len] = b
+	p.utf8Len++
+	if p.utf8Len == p.utf8Need {
+		r, _ := utf8.DecodeRune(p.utf8Buf[:p.utf8Len])
+		p.utf8Need = 0
+		p.advanceRune(r)
+	}
+}
+
+func (p *Parser) startUTF8(b byte, need int) {
+	p.utf8Buf[0] = b
+	p.utf8Len = 1
+	p.utf8Need = need
+}
+
+// advanceRune 以一个解码后的字符驱动状态机
+func (p *Parser) advanceRune(r rune) {
+	// 任何状态下都有效的转换
+	switch {
+	case r == 0x18 || r == 0x1a: // CAN, SUB：中止当前序列
+		p.abort()
+		p.handler.Execute(byte(r))
+		return
+	case r == 0x1b: // ESC
+		p.exitState()
+		p.clear()
+		p.state = stateEscape
+		return
+	case r >= 0x80 && r <= 0x9f: // C1 控制字符
+		p.advanceC1(byte(r))
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		switch {
+		case r < 0x20:
+			p.handler.Execute(byte(r))
+		case r == 0x7f:
+			// 忽略 DEL
+		default:
+			p.handler.Print(r)
+		}
+
+	case stateEscape:
+		switch {
+		case r < 0x20:
+			p.handler.Execute(byte(r))
+		case r <= 0x2f:
+			p.collect(byte(r))
+			p.state = stateEscapeIntermediate
+		case r == '[':
+			p.clear()
+			p.state = stateCsiEntry
+		case r == ']':
+			p.osc = p.osc[:0]
+			p.state = stateOscString
+		case r == 'P':
+			p.clear()
+			p.state = stateDcsEntry
+		case r == 'X' || r == '^' || r == '_':
+			p.state = stateSosPmApcString
+		case r <= 0x7e:
+			p.handler.EscDispatch(p.intermeds, byte(r))
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case r < 0x20:
+			p.handler.Execute(byte(r))
+		case r <= 0x2f:
+			p.collect(byte(r))
+		case r <= 0x7e:
+			p.handler.EscDispatch(p.intermeds, byte(r))
+			p.state = stateGround
+		}
+
+	case stateCsiEntry, stateCsiParam, stateCsiIntermediate:
+		switch {
+		case r < 0x20:
+			p.handler.Execute(byte(r))
+		case r <= 0x2f:
+			p.collect(byte(r))
+			p.state = stateCsiIntermediate
+		case r <= 0x3b:
+			if p.state == stateCsiIntermediate {
+				p.state = stateCsiIgnore
+				return
+			}
+			p.param(byte(r))
+			p.state = stateCsiParam
+		case r <= 0x3f:
+			// 私有标记只能出现在参数之前
+			if p.state != stateCsiEntry {
+				p.state = stateCsiIgnore
+				return
+			}
+			p.collect(byte(r))
+			p.state = stateCsiParam
+		case r <= 0x7e:
+			p.handler.CsiDispatch(p.finishParams(), p.intermeds, byte(r))
+			p.state = stateGround
+		}
+
+	case stateCsiIgnore:
+		switch {
+		case r < 0x20:
+			p.handler.Execute(byte(r))
+		case r >= 0x40 && r <= 0x7e:
+			p.state = stateGround
+		}
+
+	case stateDcsEntry, stateDcsParam, stateDcsIntermediate:
+		switch {
+		case r < 0x20:
+			// DCS 头部中的控制字符被忽略
+		case r <= 0x2f:
+			p.collect(byte(r))
+			p.state = stateDcsIntermediate
+		case r <= 0x3b:
+			if p.state == stateDcsIntermediate {
+				p.state = stateDcsIgnore
+				return
+			}
+			p.param(byte(r))
+			p.state = stateDcsParam
+		case r <= 0x3f:
+			if p.state != stateDcsEntry {
+				p.state = stateDcsIgnore
+				return
+			}
+			p.collect(byte(r))
+			p.state = stateDcsParam
+		case r <= 0x7e:
+			p.handler.DcsHook(p.finishParams(), p.intermeds, byte(r))
+			p.state = stateDcsPassthrough
+		}
+
+	case stateDcsPassthrough:
+		if r != 0x7f {
+			p.handler.DcsPut(r)
+		}
+
+	case stateOscString:
+		switch {
+		case r == 0x07: // BEL 结束（xterm 兼容）
+			p.dispatchOsc(true)
+			p.state = stateGround
+		case r < 0x20:
+			// 忽略其他控制字符
+		default:
+			if len(p.osc) < maxOscLength {
+				p.osc = utf8.AppendRune(p.osc, r)
+			}
+		}
+
+	case stateDcsIgnore, stateSosPmApcString:
+		// 忽略直到 ST
+	}
+}
+
+// advanceC1 处理 C1 控制字符
+func (p *Parser) advanceC1(b byte) {
+	switch b {
+	case 0x90: // DCS
+		p.exitState()
+		p.clear()
+		p.state = stateDcsEntry
+	case 0x98, 0x9e, 0x9f: // SOS, PM, APC
+		p.exitState()
+		p.state = stateSosPmApcString
+	case 0x9b: // CSI
+		p.exitState()
+		p.clear()
+		p.state = stateCsiEntry
+	case 0x9c: // ST
+		p.exitState()
+		p.state = stateGround
+	case 0x9d: // OSC
+		p.exitState()
+		p.osc = p.osc[:0]
+		p.state = stateOscString
+	default:
+		p.exitState()
+		p.handler.Execute(b)
+		p.state = stateGround
+	}
+}
+
+// exitState 离开当前状态时的动作：结束 OSC 字符串或 DCS 透传
+func (p *Parser) exitState() {
+	switch p.state {
+	case stateOscString:
+		p.dispatchOsc(false)
+	case stateDcsPassthrough:
+		p.handler.DcsUnhook()
+	}
+}
+
+// abort 中止当前序列，未结束的 OSC 字符串被丢弃
+func (p *Parser) abort() {
+	if p.state == stateDcsPassthrough {
+		p.handler.DcsUnhook()
+	}
+	p.osc = p.osc[:0]
+	p.state = stateGround
+}
+
+// clear 清空参数和中间字节
+func (p *Parser) clear() {
+	p.values = p.values[:0]
+	p.starts = append(p.starts[:0], 0)
+	p.current = 0
+	p.overflow = false
+	p.intermeds = p.intermeds[:0]
+}
+
+func (p *Parser) collect(b byte) {
+	if len(p.intermeds) < maxIntermediates+1 {
+		p.intermeds = append(p.intermeds, b)
+	}
+}
+
+// param 处理参数字节：数字、子参数分隔符 ':' 和参数分隔符 ';'
+func (p *Parser) param(b byte) {
+	if p.overflow {
+		return
+	}
+	switch b {
+	case ';':
+		p.values = append(p.values, p.current)
+		p.current = 0
+		if len(p.starts) >= maxParams || len(p.values) >= maxValues {
+			p.overflow = true
+			return
+		}
+		p.starts = append(p.starts, len(p.values))
+	case ':':
+		p.values = append(p.values, p.current)
+		p.current = 0
+		// 子参数不受参数个数的限制，单独限制总数，避免 values 无限增长
+		if len(p.values) >= maxValues {
+			p.overflow = true
+		}
+	default:
+		p.current = min(p.current*10+int(b-'0'), maxParamValue)
+	}
+}
+
+// finishParams 结束参数收集并返回参数列表，没有参数时返回一个值为0的参数
+func (p *Parser) finishParams() Params {
+	if !p.overflow {
+		p.values = append(p.values, p.current)
+	}
+	p.params = p.params[:0]
+	for i, start := range p.starts {
+		end := len(p.values)
+		if i+1 < len(p.starts) {
+			end = p.starts[i+1]
+		}
+		p.params = append(p.params, p.values[start:end:end])
+	}
+	return p.params
+}
+
+// dispatchOsc 将 OSC 字符串按分号切分后分发
+func (p *Parser) dispatchOsc(bellTerminated bool) {
+	var params [][]byte
+	start := 0
+	for i, b := range p.osc {
+		if b == ';' {
+			params = append(params, p.osc[start:i])
+			start = i + 1
+		}
+	}
+	params = append(params, p.osc[start:])
+	p.handler.OscDispatch(params, bellTerminated)
+	p.osc = p.osc[:0]
+}
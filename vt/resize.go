@@ -0,0 +1,199 @@
+package vt
+
+// Resize 调整终端尺寸：重排主屏幕和历史中的软换行行，调整备用屏幕
+// 只调整模拟器本身，通知子进程由 pty 一侧负责
+func (e *Emulator) Resize(cols, rows int) {
+	cols, rows = max(1, cols), max(1, rows)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if cols == e.screenWidth && rows == e.screenHeight {
+		return
+	}
+
+	// 在备用屏幕时，主屏幕的光标保存在 primarySaved 中
+	cursorX, cursorY := e.cursorX, e.cursorY
+	if e.altScreen {
+		cursorX, cursorY = e.primarySaved.x, e.primarySaved.y
+	}
+	cursorX, cursorY = e.reflowPrimary(cols, rows, cursorX, cursorY)
+
+	// 备用屏幕不重排，直接截断或补齐
+	e.altBuffer = resizeScreenBuffer(e.altBuffer, cols, rows)
+	e.altWrapped = make([]bool, rows)
+
+	e.screenWidth = cols
+	e.screenHeight = rows
+	e.maxLines = rows
+	e.scrollTop = 0
+	e.scrollBottom = rows - 1
+	e.viewOffset = 0
+	e.dirty = make([]bool, rows)
+	e.markAllDirty()
+
+	if e.altScreen {
+		e.screenBuffer = e.altBuffer
+		e.wrapped = e.altWrapped
+		e.primarySaved.x, e.primarySaved.y = cursorX, cursorY
+		e.cursorX = min(e.cursorX, cols-1)
+		e.cursorY = min(e.cursorY, rows-1)
+	} else {
+		e.screenBuffer = e.primaryBuffer
+		e.wrapped = e.primaryWrapped
+		e.cursorX, e.cursorY = cursorX, cursorY
+	}
+	e.altSaved.x = min(e.altSaved.x, cols-1)
+	e.altSaved.y = min(e.altSaved.y, rows-1)
+}
+
+// reflowPrimary 按新宽度重排历史和主屏幕：软换行连接的物理行先拼成逻辑行，再按新宽度重新折行
+// 返回光标在新主屏幕中的位置
+func (e *Emulator) reflowPrimary(cols, rows, cursorX, cursorY int) (int, int) {
+	// 所有物理行：历史在前，主屏幕在后
+	historyLen := e.history.len()
+	physical := make([][]Cell, 0, historyLen+e.screenHeight)
+	wrapped := make([]bool, 0, historyLen+e.screenHeight)
+	for y := 0; y < historyLen; y++ {
+		line := e.history.at(y)
+		physical = append(physical, line.cells)
+		wrapped = append(wrapped, line.wrapped)
+	}
+	physical = append(physical, e.primaryBuffer...)
+	wrapped = append(wrapped, e.primaryWrapped...)
+
+	// 光标下方的空行不参与重排
+	cursorRow := historyLen + cursorY
+	lastRow := cursorRow
+	for y := len(physical) - 1; y > cursorRow; y-- {
+		if !isBlankRow(physical[y]) {
+			lastRow = y
+			break
+		}
+	}
+
+	// 拼接逻辑行并重新折行
+	var newRows [][]Cell
+	var newWrapped []bool
+	newCursorRow, newCursorX := 0, 0
+	var line []Cell
+	cursorOffset := -1
+	for y := 0; y <= lastRow; y++ {
+		if y == cursorRow {
+			cursorOffset = len(line) + cursorX
+		}
+		line = append(line, physical[y]...)
+		if wrapped[y] && y < lastRow {
+			continue
+		}
+
+		// 去掉行尾空白，但保留光标之前的内容
+		end := len(line)
+		for end > 0 && end > cursorOffset && isBlankCell(line[end-1]) {
+			end--
+		}
+		lineRows, lineWrapped, cy, cx := rewrapLine(line[:end], cols, cursorOffset)
+		if cursorOffset >= 0 {
+			newCursorRow, newCursorX = len(newRows)+cy, cx
+		}
+		newRows = append(newRows, lineRows...)
+		newWrapped = append(newWrapped, lineWrapped...)
+		line = nil
+		cursorOffset = -1
+	}
+
+	// 新屏幕显示最后 rows 行，并保证光标所在行可见
+	start := max(0, len(newRows)-rows)
+	if newCursorRow < start {
+		start = newCursorRow
+	}
+
+	e.primaryBuffer = newScreenBuffer(cols, rows)
+	e.primaryWrapped = make([]bool, rows)
+	for y := 0; y < rows && start+y < len(newRows); y++ {
+		copy(e.primaryBuffer[y], newRows[start+y])
+		e.primaryWrapped[y] = newWrapped[start+y]
+	}
+
+	// 其余行进入历史，超出容量的最老行被丢弃
+	e.history.clear()
+	for y := max(0, start-e.history.limit); y < start; y++ {
+		e.history.push(newRows[y], newWrapped[y])
+	}
+
+	return min(newCursorX, cols), newCursorRow - start
+}
+
+// rewrapLine 将一个逻辑行按宽度折成多行，宽字符不会被拆到两行
+// cursor 为光标在逻辑行中的偏移（-1 表示光标不在该行），返回光标所在的行和列
+func rewrapLine(cells []Cell, cols, cursor int) (rows [][]Cell, wrapped []bool, cursorRow, cursorCol int) {
+	row := make([]Cell, 0, cols)
+	for i := 0; i < len(cells); i++ {
+		cell := cells[i]
+		if cell.Width == 0 || cell == wrapPaddingCell {
+			// 宽字符的占位符随宽字符一起处理，孤立的占位符和换行填充丢弃
+			if i == cursor {
+				cursorRow, cursorCol = len(rows), max(0, len(row)-1)
+			}
+			continue
+		}
+		if len(row) > 0 && len(row)+cell.Width > cols {
+			rows = append(rows, padRow(row, cols))
+			wrapped = append(wrapped, true)
+			row = make([]Cell, 0, cols)
+		}
+		if i == cursor {
+			cursorRow, cursorCol = len(rows), len(row)
+		}
+		row = append(row, cell)
+		if cell.Width == 2 {
+			row = append(row, Cell{Char: "", Width: 0, Style: cell.Style})
+		}
+	}
+	if cursor >= len(cells) {
+		cursorRow, cursorCol = len(rows), len(row)+cursor-len(cells)
+	}
+	rows = append(rows, padRow(row, cols))
+	wrapped = append(wrapped, false)
+	return rows, wrapped, cursorRow, cursorCol
+}
+
+// padRow 将行补齐（或截断）到指定宽度
+func padRow(row []Cell, cols int) []Cell {
+	if len(row) > cols {
+		return row[:cols]
+	}
+	for len(row) < cols {
+		row = append(row, Cell{Char: " ", Width: 1})
+	}
+	return row
+}
+
+// resizeScreenBuffer 截断或补齐屏幕缓冲区，不做重排
+func resizeScreenBuffer(buffer [][]Cell, cols, rows int) [][]Cell {
+	resized := newScreenBuffer(cols, rows)
+	for y := 0; y < rows && y < len(buffer); y++ {
+		copy(resized[y], buffer[y])
+		// 截断处如果拆开了宽字符，清除残留的一半
+		if cols < len(buffer[y]) && resized[y][cols-1].Width == 2 {
+			resized[y][cols-1] = Cell{Char: " ", Width: 1}
+		}
+	}
+	return resized
+}
+
+// wrapPaddingCell 宽字符在行尾放不下而换行时，行尾剩余格子使用的填充单元格，显示为空白
+var wrapPaddingCell = Cell{Char: "", Width: 1}
+
+// isBlankCell 是否为默认样式的空白单元格
+func isBlankCell(cell Cell) bool {
+	return (cell.Char == " " || cell == wrapPaddingCell) && cell.Style == Style{}
+}
+
+// isBlankRow 整行是否都是默认样式的空白
+func isBlankRow(row []Cell) bool {
+	for _, cell := range row {
+		if !isBlankCell(cell) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package vt
+
+// savedCursor DECSC 保存的光标位置和画笔样式
+type savedCursor struct {
+	x, y int
+	pen  Style
+}
+
+// newScreenBuffer 创建一个填满空白单元格的屏幕缓冲区
+func newScreenBuffer(width, height int) [][]Cell {
+	buffer := make([][]Cell, height)
+	for y := range buffer {
+		buffer[y] = make([]Cell, width)
+		for x := range buffer[y] {
+			buffer[y][x] = Cell{Char: " ", Width: 1}
+		}
+	}
+	return buffer
+}
+
+// saveCursor 保存当前屏幕的光标位置和属性（DECSC）
+func (e *Emulator) saveCursor() {
+	saved := savedCursor{x: e.cursorX, y: e.cursorY, pen: e.pen}
+	if e.altScreen {
+		e.altSaved = saved
+	} else {
+		e.primarySaved = saved
+	}
+}
+
+// restoreCursor 恢复当前屏幕保存的光标位置和属性（DECRC）
+func (e *Emulator) restoreCursor() {
+	saved := e.primarySaved
+	if e.altScreen {
+		saved = e.altSaved
+	}
+	e.cursorX = max(0, min(e.screenWidth-1, saved.x))
+	e.cursorY = max(0, min(e.screenHeight-1, saved.y))
+	e.pen = saved.pen
+}
+
+// switchScreen 在主屏幕和备用屏幕之间切换
+func (e *Emulator) switchScreen(alt bool) {
+	if e.altScreen == alt {
+		return
+	}
+	e.altScreen = alt
+	if alt {
+		e.screenBuffer = e.altBuffer
+		e.wrapped = e.altWrapped
+	} else {
+		e.screenBuffer = e.primaryBuffer
+		e.wrapped = e.primaryWrapped
+	}
+	e.viewOffset = 0
+	e.markAllDirty()
+}
+
+// clearAltBuffer 清空备用屏幕
+func (e *Emulator) clearAltBuffer() {
+	for y := range e.altBuffer {
+		for x := range e.altBuffer[y] {
+			e.altBuffer[y][x] = e.blankCell()
+		}
+		e.altWrapped[y] = false
+	}
+}
+
+// setPrivateModes 处理 CSI ? Pm h / CSI ? Pm l（DECSET/DECRST）
+func (e *Emulator) setPrivateModes(params Params, enable bool) {
+	for _, param := range params {
+		mode := param[0]
+		switch mode {
+		case 1: // DECCKM 应用光标键
+			e.appCursorKeys = enable
+		case 9: // X10 鼠标
+			e.setMouseTracking(MouseTrackingX10, enable)
+		case 1000: // 鼠标按下和松开
+			e.setMouseTracking(MouseTrackingNormal, enable)
+		case 1002: // 鼠标按键拖动
+			e.setMouseTracking(MouseTrackingButton, enable)
+		case 1003: // 鼠标所有移动
+			e.setMouseTracking(MouseTrackingAny, enable)
+		case 1006: // SGR 鼠标编码
+			e.mouseSGR = enable
+		case 47: // 备用屏幕
+			e.switchScreen(enable)
+		case 1047: // 备用屏幕，退出时清空
+			if !enable && e.altScreen {
+				e.clearAltBuffer()
+			}
+			e.switchScreen(enable)
+		case 1048: // 保存/恢复光标
+			if enable {
+				e.saveCursor()
+			} else {
+				e.restoreCursor()
+			}
+		case 1049: // 保存光标并切换到清空的备用屏幕
+			if enable {
+				if !e.altScreen {
+					e.saveCursor()
+					e.switchScreen(true)
+					e.clearAltBuffer()
+				}
+			} else if e.altScreen {
+				e.switchScreen(false)
+				e.restoreCursor()
+			}
+		case 2004: // 括号粘贴
+			e.bracketedPaste = enable
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package vt
+
+// isFullScreenRegion 滚动区域是否覆盖整个屏幕
+func (e *Emulator) isFullScreenRegion() bool {
+	return e.scrollTop == 0 && e.scrollBottom == e.screenHeight-1
+}
+
+// lineFeed 换行（IND）：光标在底边距时滚动区域，否则下移一行
+func (e *Emulator) lineFeed() {
+	if e.cursorY == e.scrollBottom {
+		e.scrollRegionUp(1)
+	} else if e.cursorY < e.screenHeight-1 {
+		e.cursorY++
+	}
+}
+
+// reverseIndex 反向换行（RI）：光标在顶边距时区域向下滚动，否则上移一行
+func (e *Emulator) reverseIndex() {
+	if e.cursorY == e.scrollTop {
+		e.scrollRegionDown(1)
+	} else if e.cursorY > 0 {
+		e.cursorY--
+	}
+}
+
+// scrollRegionUp 滚动区域内容上移n行，底部补空行
+// 只有主屏幕的整屏滚动才会把移出的行保存到历史中
+func (e *Emulator) scrollRegionUp(n int) {
+	if !e.altScreen && e.isFullScreenRegion() {
+		for y := 0; y < min(n, e.screenHeight); y++ {
+			e.pushHistory(e.screenBuffer[y], e.wrapped[y])
+		}
+	}
+	e.shiftRowsUp(e.scrollTop, e.scrollBottom, n)
+}
+
+// scrollRegionDown 滚动区域内容下移n行，顶部补空行
+func (e *Emulator) scrollRegionDown(n int) {
+	e.shiftRowsDown(e.scrollTop, e.scrollBottom, n)
+}
+
+// shiftRowsUp 将 [top, bottom] 行区间内容上移n行，底部补空行
+func (e *Emulator) shiftRowsUp(top, bottom, n int) {
+	height := bottom - top + 1
+	n = min(n, height)
+	if n <= 0 {
+		return
+	}
+
+	e.markDirtyRange(top, bottom)
+	region := e.screenBuffer[top : bottom+1]
+	removed := append([][]Cell(nil), region[:n]...)
+	copy(region, region[n:])
+	wrapped := e.wrapped[top : bottom+1]
+	copy(wrapped, wrapped[n:])
+	for i := height - n; i < height; i++ {
+		wrapped[i] = false
+	}
+	// 复用移出的行作为新的空行
+	for i, row := range removed {
+		for x := range row {
+			row[x] = e.blankCell()
+		}
+		region[height-n+i] = row
+	}
+}
+
+// shiftRowsDown 将 [top, bottom] 行区间内容下移n行，顶部补空行
+func (e *Emulator) shiftRowsDown(top, bottom, n int) {
+	height := bottom - top + 1
+	n = min(n, height)
+	if n <= 0 {
+		return
+	}
+
+	e.markDirtyRange(top, bottom)
+	region := e.screenBuffer[top : bottom+1]
+	removed := append([][]Cell(nil), region[height-n:]...)
+	copy(region[n:], region[:height-n])
+	wrapped := e.wrapped[top : bottom+1]
+	copy(wrapped[n:], wrapped[:height-n])
+	for i := 0; i < n; i++ {
+		wrapped[i] = false
+	}
+	for i, row := range removed {
+		for x := range row {
+			row[x] = e.blankCell()
+		}
+		region[i] = row
+	}
+}
+
+// setScrollRegion 处理 DECSTBM (CSI t;b r)，设置上下边距并将光标移到左上角
+func (e *Emulator) setScrollRegion(params Params) {
+	top := params.Get(0, 1)
+	bottom := min(params.Get(1, e.screenHeight), e.screenHeight)
+	if top >= bottom {
+		return
+	}
+	e.scrollTop = top - 1
+	e.scrollBottom = bottom - 1
+	e.cursorX = 0
+	e.cursorY = 0
+}
@@ -0,0 +1,78 @@
+package vt
+
+const (
+	DefaultScrollbackLines = 1000   // 未配置时保存的历史行数
+	MaxScrollbackLines     = 100000 // 历史行数为负（不限制）时的实际上限，避免内存无限增长
+)
+
+// historyLine 历史中的一行，只保存到最后一个非空白单元格为止
+type historyLine struct {
+	cells   []Cell
+	wrapped bool // 是否因自动换行延续到下一行
+}
+
+// scrollback 滚动历史，环形缓冲区：容量未满时追加，满了之后覆盖最老的一行，push 为 O(1)
+type scrollback struct {
+	lines []historyLine
+	start int // 最老一行在 lines 中的下标
+	count int
+	limit int // 最多保存的行数，为 0 时不保存历史
+	// 被覆盖或丢弃的行数，只增不减，加上下标即为历史行的行号
+	// 环形缓冲区满了之后已有行的行号保持不变，搜索结果和选区不会因新的输出而错位
+	dropped int
+}
+
+// newScrollback 创建最多保存 limit 行的历史，limit 为负时使用 MaxScrollbackLines
+func newScrollback(limit int) scrollback {
+	if limit < 0 || limit > MaxScrollbackLines {
+		limit = MaxScrollbackLines
+	}
+	return scrollback{limit: limit}
+}
+
+// len 返回历史行数
+func (s *scrollback) len() int {
+	return s.count
+}
+
+// at 返回第i行（0为最老的一行）
+func (s *scrollback) at(i int) historyLine {
+	return s.lines[(s.start+i)%len(s.lines)]
+}
+
+// push 追加一行，已满时覆盖最老的一行并复用它的内存
+// 软换行的行保留完整宽度，否则重排时行尾的空格会丢失
+func (s *scrollback) push(row []Cell, wrapped bool) {
+	if s.limit == 0 {
+		s.dropped++
+		return
+	}
+	end := len(row)
+	if !wrapped {
+		for end > 0 && isBlankCell(row[end-1]) {
+			end--
+		}
+	}
+
+	var line *historyLine
+	if s.count < s.limit {
+		if len(s.lines) < s.limit {
+			s.lines = append(s.lines, historyLine{})
+		}
+		line = &s.lines[(s.start+s.count)%len(s.lines)]
+		s.count++
+	} else {
+		line = &s.lines[s.start]
+		s.start = (s.start + 1) % len(s.lines)
+		s.dropped++
+	}
+	line.cells = append(line.cells[:0], row[:end]...)
+	line.wrapped = wrapped
+}
+
+// clear 清空历史，保留容量设置，重新填入的行从原来最老一行的行号开始编号
+func (s *scrollback) clear() {
+	dropped := s.dropped
+	*s = newScrollback(s.limit)
+	s.dropped = dropped
+}
@@ -0,0 +1,83 @@
+package vt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Match 一个搜索结果：第 Line 行的 [Start, End) 列
+// 行号从第一行输出开始编号，历史之后依次是屏幕上的行，与 Snapshot 中
+// FirstLine + HistoryLines - ViewOffset + y 的计算方式一致
+// 历史丢弃最老的行时其余行的行号不变，保存的结果在新的输出之后仍然有效
+type Match struct {
+	Line       int
+	Start, End int
+}
+
+// Search 在历史和当前屏幕中查找所有匹配，按行和列排序，不跨行匹配
+// 备用屏幕没有历史，只搜索屏幕
+func (e *Emulator) Search(re *regexp.Regexp) []Match {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var matches []Match
+	first, historyLines := e.history.dropped, e.history.len()
+	if !e.altScreen {
+		for i := 0; i < historyLines; i++ {
+			matches = appendMatches(matches, re, e.history.at(i).cells, first+i)
+		}
+	}
+	for y, row := range e.screenBuffer {
+		matches = appendMatches(matches, re, row, first+historyLines+y)
+	}
+	return matches
+}
+
+// appendMatches 将一行中的匹配追加到 matches，匹配的字节偏移换算成列
+func appendMatches(matches []Match, re *regexp.Regexp, row []Cell, line int) []Match {
+	var text strings.Builder
+	// columns[i] 为文本第i个字节所在的列，最后一项是行的总列数
+	columns := make([]int, 0, len(row)+1)
+	for x, cell := range row {
+		switch {
+		case cell.Width == 0:
+			continue
+		case cell.Char == "":
+			text.WriteByte(' ')
+		default:
+			text.WriteString(cell.Char)
+		}
+		for len(columns) < text.Len() {
+			columns = append(columns, x)
+		}
+	}
+	columns = append(columns, len(row))
+
+	for _, loc := range re.FindAllStringIndex(text.String(), -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, Match{Line: line, Start: columns[loc[0]], End: columns[loc[1]]})
+	}
+	return matches
+}
+
+// ScrollToLine 滚动视图使第 line 行（行号规则同 Match）显示在屏幕中间，屏幕上的行回到底部即可看到
+// 已被丢弃的行滚动到最老的一行
+func (e *Emulator) ScrollToLine(line int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.altScreen {
+		return
+	}
+	historyLines := e.history.len()
+	i := line - e.history.dropped
+	offset := 0
+	if i < historyLines {
+		offset = min(historyLines, historyLines-i+e.screenHeight/2)
+	}
+	if offset != e.viewOffset {
+		e.viewOffset = offset
+		e.viewDirty = true
+	}
+}
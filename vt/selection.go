@@ -0,0 +1,121 @@
+package vt
+
+import "strings"
+
+// Point 历史和屏幕中的一个位置，行号规则同 Match
+type Point struct {
+	Line, Col int
+}
+
+// Before 是否在 q 之前
+func (p Point) Before(q Point) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// wordDelimiters 双击选词时作为分隔符的字符，路径和 URL 中常见的 / . - _ : ~ 不算分隔符
+const wordDelimiters = " \t\"'`()[]{}<>|;,&=$"
+
+// Lines 返回屏幕最后一行之后的行号，即可以选择的行号上限（不包含）
+func (e *Emulator) Lines() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.history.dropped + e.history.len() + e.screenHeight
+}
+
+// FirstLine 返回最老的历史行的行号，即可以选择的行号下限
+// 历史丢弃最老的行时下限增大，其余行的行号不变，选区不会因新的输出而错位
+func (e *Emulator) FirstLine() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.history.dropped
+}
+
+// line 返回第i行的单元格和软换行标记，超出范围（包括已被丢弃的行）时返回 nil
+// 备用屏幕时历史部分不可见，返回空行
+func (e *Emulator) line(i int) ([]Cell, bool) {
+	i -= e.history.dropped
+	historyLines := e.history.len()
+	switch {
+	case i < 0:
+		return nil, false
+	case i < historyLines:
+		if e.altScreen {
+			return nil, false
+		}
+		line := e.history.at(i)
+		return line.cells, line.wrapped
+	case i-historyLines < e.screenHeight:
+		return e.screenBuffer[i-historyLines], e.wrapped[i-historyLines]
+	}
+	return nil, false
+}
+
+// Text 返回 [from, to] 范围（包含两端的单元格）内的文本
+// 宽字符只输出一次，换行填充不输出，每行去掉行尾空白，软换行的行直接连接，其余行之间用换行符分隔
+func (e *Emulator) Text(from, to Point) string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if to.Before(from) {
+		from, to = to, from
+	}
+
+	var b strings.Builder
+	for i := from.Line; i <= to.Line; i++ {
+		cells, wrapped := e.line(i)
+		start, end := 0, len(cells)
+		if i == from.Line {
+			start = from.Col
+		}
+		if i == to.Line {
+			end = min(end, to.Col+1)
+		}
+		// 从宽字符的后半部分开始时包含整个字符
+		if start > 0 && start < len(cells) && cells[start].Width == 0 {
+			start--
+		}
+
+		var line strings.Builder
+		for x := start; x < end; x++ {
+			if cell := cells[x]; cell.Width > 0 && cell != wrapPaddingCell {
+				line.WriteString(cell.Char)
+			}
+		}
+		text := line.String()
+		if !wrapped || i == to.Line {
+			text = strings.TrimRight(text, " ")
+		}
+		b.WriteString(text)
+		if i < to.Line && !wrapped {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// WordAt 返回 p 所在单词的起止位置（包含两端），p 在分隔符上时只选中该单元格
+func (e *Emulator) WordAt(p Point) (Point, Point) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	cells, _ := e.line(p.Line)
+	if p.Col < 0 || p.Col >= len(cells) {
+		return p, p
+	}
+	isWord := func(x int) bool {
+		cell := cells[x]
+		if cell.Width == 0 {
+			return x > 0 && cells[x-1].Width == 2
+		}
+		return cell.Char != "" && !strings.Contains(wordDelimiters, cell.Char)
+	}
+	if !isWord(p.Col) {
+		return p, p
+	}
+	start, end := p.Col, p.Col
+	for start > 0 && isWord(start-1) {
+		start--
+	}
+	for end < len(cells)-1 && isWord(end+1) {
+		end++
+	}
+	return Point{Line: p.Line, Col: start}, Point{Line: p.Line, Col: end}
+}
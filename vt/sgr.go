@@ -0,0 +1,219 @@
+package vt
+
+// 颜色类型
+const (
+	colorDefault uint8 = iota // 默认前景/背景色
+	colorIndexed              // 256色调色板索引
+	colorRGB                  // 24位真彩色
+)
+
+// Color 单元格颜色，可以是默认色、调色板索引或真彩色
+type Color struct {
+	kind    uint8
+	index   uint8
+	r, g, b uint8
+}
+
+// Attr 单元格显示属性位
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrDim
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrStrike
+)
+
+// Style 单元格样式：前景色、背景色和属性
+type Style struct {
+	Fg   Color
+	Bg   Color
+	Attr Attr
+}
+
+// RGB 解析后的实际颜色
+type RGB struct {
+	R, G, B uint8
+}
+
+var (
+	DefaultFg = RGB{220, 220, 220} // 默认前景色（浅灰色）
+	DefaultBg = RGB{30, 30, 30}    // 默认背景色，与终端背景一致
+)
+
+// 16色基础调色板（xterm 默认值）
+var basePalette = [16]RGB{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteColor 返回256色调色板中索引对应的颜色
+func paletteColor(index uint8) RGB {
+	switch {
+	case index < 16:
+		return basePalette[index]
+	case index < 232:
+		// 6x6x6 颜色立方体
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		i := index - 16
+		return RGB{levels[i/36], levels[(i/6)%6], levels[i%6]}
+	default:
+		// 24级灰度
+		v := 8 + (index-232)*10
+		return RGB{v, v, v}
+	}
+}
+
+// resolve 将颜色解析为实际RGB值，def为默认色时使用的值
+func (c Color) resolve(def RGB) RGB {
+	switch c.kind {
+	case colorIndexed:
+		return paletteColor(c.index)
+	case colorRGB:
+		return RGB{c.r, c.g, c.b}
+	}
+	return def
+}
+
+// Colors 根据样式计算实际绘制的前景色和背景色，blinkOn为闪烁文字当前是否可见
+func (s Style) Colors(blinkOn bool) (fg, bg RGB) {
+	fgColor := s.Fg
+	// 粗体时将基础8色提升为高亮色
+	if s.Attr&AttrBold != 0 && fgColor.kind == colorIndexed && fgColor.index < 8 {
+		fgColor.index += 8
+	}
+	fg = fgColor.resolve(DefaultFg)
+	bg = s.Bg.resolve(DefaultBg)
+	if s.Attr&AttrReverse != 0 {
+		fg, bg = bg, fg
+	}
+	if s.Attr&AttrDim != 0 {
+		fg = RGB{fg.R / 2, fg.G / 2, fg.B / 2}
+	}
+	if s.Attr&AttrHidden != 0 || (s.Attr&AttrBlink != 0 && !blinkOn) {
+		fg = bg
+	}
+	return fg, bg
+}
+
+// setGraphicsRendition 处理 SGR (CSI ... m) 序列，更新当前画笔样式
+func (e *Emulator) setGraphicsRendition(params Params) {
+	for i := 0; i < len(params); i++ {
+		n := params[i][0]
+		switch {
+		case n == 0:
+			e.pen = Style{}
+		case n == 1:
+			e.pen.Attr |= AttrBold
+		case n == 2:
+			e.pen.Attr |= AttrDim
+		case n == 3:
+			e.pen.Attr |= AttrItalic
+		case n == 4 && len(params[i]) > 1 && params[i][1] == 0:
+			// 4:0 表示取消下划线，其他 4:x 为各种下划线样式
+			e.pen.Attr &^= AttrUnderline
+		case n == 4 || n == 21:
+			e.pen.Attr |= AttrUnderline
+		case n == 5 || n == 6:
+			e.pen.Attr |= AttrBlink
+		case n == 7:
+			e.pen.Attr |= AttrReverse
+		case n == 8:
+			e.pen.Attr |= AttrHidden
+		case n == 9:
+			e.pen.Attr |= AttrStrike
+		case n == 22:
+			e.pen.Attr &^= AttrBold | AttrDim
+		case n == 23:
+			e.pen.Attr &^= AttrItalic
+		case n == 24:
+			e.pen.Attr &^= AttrUnderline
+		case n == 25:
+			e.pen.Attr &^= AttrBlink
+		case n == 27:
+			e.pen.Attr &^= AttrReverse
+		case n == 28:
+			e.pen.Attr &^= AttrHidden
+		case n == 29:
+			e.pen.Attr &^= AttrStrike
+		case n >= 30 && n <= 37:
+			e.pen.Fg = Color{kind: colorIndexed, index: uint8(n - 30)}
+		case n == 38:
+			color, consumed := extendedColorParams(params, i)
+			if color.kind != colorDefault {
+				e.pen.Fg = color
+			}
+			i += consumed
+		case n == 39:
+			e.pen.Fg = Color{}
+		case n >= 40 && n <= 47:
+			e.pen.Bg = Color{kind: colorIndexed, index: uint8(n - 40)}
+		case n == 48:
+			color, consumed := extendedColorParams(params, i)
+			if color.kind != colorDefault {
+				e.pen.Bg = color
+			}
+			i += consumed
+		case n == 49:
+			e.pen.Bg = Color{}
+		case n >= 90 && n <= 97:
+			e.pen.Fg = Color{kind: colorIndexed, index: uint8(n - 90 + 8)}
+		case n >= 100 && n <= 107:
+			e.pen.Bg = Color{kind: colorIndexed, index: uint8(n - 100 + 8)}
+		}
+	}
+}
+
+// extendedColorParams 解析第i个参数（38 或 48）携带的扩展颜色，返回颜色和额外消耗的参数个数
+// 支持冒号子参数形式（38:5:n、38:2::r:g:b）和分号形式（38;5;n、38;2;r;g;b）
+func extendedColorParams(params Params, i int) (Color, int) {
+	if sub := params[i][1:]; len(sub) > 0 {
+		// 38:2:cs:r:g:b 形式中带有色彩空间参数，跳过
+		if sub[0] == 2 && len(sub) >= 5 {
+			sub = append([]int{2}, sub[2:]...)
+		}
+		color, _ := parseExtendedColor(sub)
+		return color, 0
+	}
+
+	var values []int
+	for _, param := range params[i+1:] {
+		values = append(values, param[0])
+	}
+	return parseExtendedColor(values)
+}
+
+// parseExtendedColor 解析 38/48 之后的 "5;n" 或 "2;r;g;b"，返回颜色和消耗的参数个数
+func parseExtendedColor(values []int) (Color, int) {
+	if len(values) == 0 {
+		return Color{}, 0
+	}
+	switch values[0] {
+	case 5:
+		if len(values) < 2 {
+			return Color{}, len(values)
+		}
+		return Color{kind: colorIndexed, index: uint8(clampColor(values[1]))}, 2
+	case 2:
+		if len(values) < 4 {
+			return Color{}, len(values)
+		}
+		return Color{
+			kind: colorRGB,
+			r:    uint8(clampColor(values[1])),
+			g:    uint8(clampColor(values[2])),
+			b:    uint8(clampColor(values[3])),
+		}, 4
+	}
+	return Color{}, 1
+}
+
+func clampColor(v int) int {
+	return max(0, min(255, v))
+}
@@ -0,0 +1,81 @@
+package vt
+
+// Snapshot 渲染使用的屏幕快照
+// 渲染前在锁内只复制有变化的行，之后的绘制不再访问终端，解析线程不会因字形渲染被阻塞
+type Snapshot struct {
+	Rows             [][]Cell
+	Dirty            []bool // 快照中需要重画的行，绘制后由调用者清除
+	CursorX, CursorY int    // 光标在快照中的位置，向上滚动后可能超出屏幕
+	ViewOffset       int    // 向上滚动的行数，前 ViewOffset 行来自历史
+	HistoryLines     int    // 历史总行数
+	FirstLine        int    // 最老的历史行的行号（行号规则同 Match）
+}
+
+// Snapshot 将当前视图中有变化的行复制到快照中并清除终端的脏行标记，尺寸或视图变化时整屏复制
+// 向上滚动时视图由历史的最后 ViewOffset 行和屏幕的前面几行组成，屏幕内容本身不受影响
+func (e *Emulator) Snapshot(s *Snapshot) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	all := e.viewDirty || s.ViewOffset != e.viewOffset
+	if len(s.Rows) != e.screenHeight || len(s.Rows) > 0 && len(s.Rows[0]) != e.screenWidth {
+		s.Rows = newScreenBuffer(e.screenWidth, e.screenHeight)
+		s.Dirty = make([]bool, e.screenHeight)
+		all = true
+	}
+	offset := e.viewOffset
+	s.ViewOffset = offset
+	s.HistoryLines = e.history.len()
+	s.FirstLine = e.history.dropped
+	s.CursorX, s.CursorY = e.cursorX, e.cursorY+offset
+	for y := range s.Rows {
+		switch {
+		case y < offset:
+			if all {
+				e.copyHistoryRow(s.Rows[y], e.history.len()-offset+y)
+				s.Dirty[y] = true
+			}
+		case all || e.dirty[y-offset]:
+			copy(s.Rows[y], e.screenBuffer[y-offset])
+			s.Dirty[y] = true
+		}
+	}
+	// 滚出视图的屏幕行在回到底部时会随视图变化整屏重画
+	for y := range e.dirty {
+		e.dirty[y] = false
+	}
+	e.viewDirty = false
+}
+
+// copyHistoryRow 将第i行历史复制到 row，历史行只保存到最后一个非空白字符，剩余部分补空白
+func (e *Emulator) copyHistoryRow(row []Cell, i int) {
+	n := copy(row, e.history.at(i).cells)
+	for x := n; x < len(row); x++ {
+		row[x] = Cell{Char: " ", Width: 1}
+	}
+	// 历史行比屏幕宽时截断处可能拆开宽字符
+	if n == len(row) && n > 0 && row[n-1].Width == 2 {
+		row[n-1] = Cell{Char: " ", Width: 1}
+	}
+}
+
+// MarkAllDirty 标记快照的所有行需要重画，用于目标纹理重建
+func (s *Snapshot) MarkAllDirty() {
+	for y := range s.Dirty {
+		s.Dirty[y] = true
+	}
+}
+
+// MarkBlinkDirty 闪烁状态切换时标记光标所在行和包含闪烁文字的行
+func (s *Snapshot) MarkBlinkDirty() {
+	if s.CursorY >= 0 && s.CursorY < len(s.Dirty) {
+		s.Dirty[s.CursorY] = true
+	}
+	for y, row := range s.Rows {
+		for _, cell := range row {
+			if cell.Style.Attr&AttrBlink != 0 {
+				s.Dirty[y] = true
+				break
+			}
+		}
+	}
+}
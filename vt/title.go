@@ -0,0 +1,76 @@
+package vt
+
+import "bytes"
+
+const maxTitleStack = 10 // 与 xterm 一致的标题栈深度
+
+// titleEntry 标题栈中保存的窗口标题和图标名
+type titleEntry struct {
+	title    string
+	iconName string
+}
+
+// handleTitleOsc 处理 OSC 0/1/2：0 同时设置图标名和标题，1 设置图标名，2 设置标题
+func (e *Emulator) handleTitleOsc(params [][]byte) {
+	if len(params) < 2 {
+		return
+	}
+	// 标题本身可能包含分号，重新拼接
+	text := string(bytes.Join(params[1:], []byte(";")))
+	switch string(params[0]) {
+	case "0":
+		e.title = text
+		e.iconName = text
+	case "1":
+		e.iconName = text
+	case "2":
+		e.title = text
+	}
+}
+
+// pushTitle 将当前标题压栈（CSI 22 ; Ps t），which 为0表示两者、1表示图标名、2表示标题
+func (e *Emulator) pushTitle(which int) {
+	entry := titleEntry{}
+	if which == 0 || which == 1 {
+		entry.iconName = e.iconName
+	}
+	if which == 0 || which == 2 {
+		entry.title = e.title
+	}
+	if len(e.titleStack) >= maxTitleStack {
+		e.titleStack = e.titleStack[1:]
+	}
+	e.titleStack = append(e.titleStack, entry)
+}
+
+// popTitle 从栈中恢复标题（CSI 23 ; Ps t）
+func (e *Emulator) popTitle(which int) {
+	if len(e.titleStack) == 0 {
+		return
+	}
+	entry := e.titleStack[len(e.titleStack)-1]
+	e.titleStack = e.titleStack[:len(e.titleStack)-1]
+	if which == 0 || which == 1 {
+		e.iconName = entry.iconName
+	}
+	if which == 0 || which == 2 {
+		e.title = entry.title
+	}
+}
+
+// windowOps 处理 CSI Ps ; Ps t 窗口操作，目前只支持标题栈
+func (e *Emulator) windowOps(params Params) {
+	switch params.Get(0, 0) {
+	case 22:
+		e.pushTitle(params.Get(1, 0))
+	case 23:
+		e.popTitle(params.Get(1, 0))
+	}
+}
+
+// Title 返回程序设置的窗口标题
+func (e *Emulator) Title() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.title
+}